@@ -0,0 +1,401 @@
+// Package bitset implements a compact set of ISSN, using one bit per seven
+// digit prefix. Since the check digit is determined by the prefix, the whole
+// ISSN space fits into 10^7 bits, about 1.25MB, regardless of how many ISSN
+// are in the set.
+package bitset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"strings"
+
+	"github.com/miku/issnlister/atomic"
+	"github.com/miku/issnlister/issn"
+)
+
+const (
+	// numWords is the number of 64-bit words required to hold all prefixes.
+	numWords = (issn.MaxPrefix + 64) / 64
+	// magic identifies the binary serialization, the last byte is the
+	// format version.
+	magic = "ISSNSET\x01"
+)
+
+// ErrFormat is returned when reading a malformed binary set.
+var ErrFormat = errors.New("bitset: invalid format")
+
+// Set is a set of ISSN. The zero value is an empty set ready to use.
+type Set struct {
+	words []uint64
+}
+
+// New returns a new set containing the given ISSN.
+func New(vs ...issn.ISSN) *Set {
+	s := &Set{}
+	for _, v := range vs {
+		s.Add(v)
+	}
+	return s
+}
+
+func (s *Set) init() {
+	if s.words == nil {
+		s.words = make([]uint64, numWords)
+	}
+}
+
+// Add adds an ISSN to the set.
+func (s *Set) Add(v issn.ISSN) {
+	s.init()
+	s.words[v/64] |= 1 << (v % 64)
+}
+
+// Remove removes an ISSN from the set.
+func (s *Set) Remove(v issn.ISSN) {
+	if s.words == nil {
+		return
+	}
+	s.words[v/64] &^= 1 << (v % 64)
+}
+
+// Contains returns true, if the ISSN is in the set.
+func (s *Set) Contains(v issn.ISSN) bool {
+	if s.words == nil {
+		return false
+	}
+	return s.words[v/64]&(1<<(v%64)) != 0
+}
+
+// ContainsString parses a value and returns true, if it is a valid ISSN
+// contained in the set.
+func (s *Set) ContainsString(v string) bool {
+	w, err := issn.Parse(v)
+	if err != nil {
+		return false
+	}
+	return s.Contains(w)
+}
+
+// Len returns the number of ISSN in the set.
+func (s *Set) Len() int {
+	var n int
+	for _, w := range s.words {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// Rank returns the number of ISSN in the set, that are smaller than v.
+func (s *Set) Rank(v issn.ISSN) int {
+	return s.rank(int(v))
+}
+
+// rank returns the number of set bits below position i.
+func (s *Set) rank(i int) int {
+	if s.words == nil || i <= 0 {
+		return 0
+	}
+	if i > issn.MaxPrefix {
+		return s.Len()
+	}
+	var n int
+	for _, w := range s.words[:i/64] {
+		n += bits.OnesCount64(w)
+	}
+	if r := i % 64; r > 0 {
+		n += bits.OnesCount64(s.words[i/64] & (1<<r - 1))
+	}
+	return n
+}
+
+// Count returns the number of ISSN with a prefix in the half-open range
+// [lo, hi). The range is clipped to the valid prefixes.
+func (s *Set) Count(lo, hi int) int {
+	lo = min(max(lo, 0), issn.MaxPrefix+1)
+	hi = min(max(hi, 0), issn.MaxPrefix+1)
+	if s.words == nil || hi <= lo {
+		return 0
+	}
+	var (
+		n      int
+		lw, hw = lo / 64, hi / 64
+	)
+	if lw == hw {
+		mask := (uint64(1)<<(hi%64) - 1) &^ (uint64(1)<<(lo%64) - 1)
+		return bits.OnesCount64(s.words[lw] & mask)
+	}
+	n += bits.OnesCount64(s.words[lw] &^ (uint64(1)<<(lo%64) - 1))
+	for _, w := range s.words[lw+1 : hw] {
+		n += bits.OnesCount64(w)
+	}
+	if r := hi % 64; r > 0 {
+		n += bits.OnesCount64(s.words[hw] & (uint64(1)<<r - 1))
+	}
+	return n
+}
+
+// BlockCount returns the number of ISSN in a four digit block (0-9999),
+// which holds at most 1000 ISSN.
+func (s *Set) BlockCount(block int) int {
+	return s.Count(block*1000, (block+1)*1000)
+}
+
+// Each calls f for each ISSN in the set in ascending order, until f returns
+// false.
+func (s *Set) Each(f func(v issn.ISSN) bool) {
+	for i, w := range s.words {
+		for w != 0 {
+			t := bits.TrailingZeros64(w)
+			if !f(issn.ISSN(i*64 + t)) {
+				return
+			}
+			w &= w - 1
+		}
+	}
+}
+
+// Values returns all ISSN in the set in ascending order.
+func (s *Set) Values() []issn.ISSN {
+	result := make([]issn.ISSN, 0, s.Len())
+	s.Each(func(v issn.ISSN) bool {
+		result = append(result, v)
+		return true
+	})
+	return result
+}
+
+// Strings returns all ISSN in the set in ascending order, in canonical form.
+func (s *Set) Strings() []string {
+	result := make([]string, 0, s.Len())
+	s.Each(func(v issn.ISSN) bool {
+		result = append(result, v.String())
+		return true
+	})
+	return result
+}
+
+// Clone returns a copy of the set.
+func (s *Set) Clone() *Set {
+	t := &Set{}
+	if s.words != nil {
+		t.words = make([]uint64, numWords)
+		copy(t.words, s.words)
+	}
+	return t
+}
+
+// Equal returns true, if both sets contain the same ISSN.
+func (s *Set) Equal(other *Set) bool {
+	for i := 0; i < numWords; i++ {
+		if s.word(i) != other.word(i) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Set) word(i int) uint64 {
+	if s.words == nil {
+		return 0
+	}
+	return s.words[i]
+}
+
+// combine returns a new set, where each word is the result of f applied to
+// the corresponding words of s and other.
+func (s *Set) combine(other *Set, f func(a, b uint64) uint64) *Set {
+	t := &Set{words: make([]uint64, numWords)}
+	for i := range t.words {
+		t.words[i] = f(s.word(i), other.word(i))
+	}
+	return t
+}
+
+// Union returns a new set with ISSN, that are in s or other.
+func (s *Set) Union(other *Set) *Set {
+	return s.combine(other, func(a, b uint64) uint64 { return a | b })
+}
+
+// Intersection returns a new set with ISSN, that are in both s and other.
+func (s *Set) Intersection(other *Set) *Set {
+	return s.combine(other, func(a, b uint64) uint64 { return a & b })
+}
+
+// Difference returns a new set with ISSN, that are in s but not in other.
+func (s *Set) Difference(other *Set) *Set {
+	return s.combine(other, func(a, b uint64) uint64 { return a &^ b })
+}
+
+// WriteTo writes the binary serialization of the set: an eight byte magic
+// string, the number of elements as uint64 and the bitmap as little endian
+// uint64 words.
+func (s *Set) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var (
+		n   int64
+		buf [8]byte
+	)
+	k, err := io.WriteString(bw, magic)
+	n += int64(k)
+	if err != nil {
+		return n, err
+	}
+	binary.LittleEndian.PutUint64(buf[:], uint64(s.Len()))
+	k, err = bw.Write(buf[:])
+	n += int64(k)
+	if err != nil {
+		return n, err
+	}
+	for i := 0; i < numWords; i++ {
+		binary.LittleEndian.PutUint64(buf[:], s.word(i))
+		k, err = bw.Write(buf[:])
+		n += int64(k)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+// ReadFrom replaces the contents of the set with the binary serialization
+// read from r.
+func (s *Set) ReadFrom(r io.Reader) (int64, error) {
+	br := bufio.NewReader(r)
+	var (
+		n   int64
+		buf [8]byte
+	)
+	k, err := io.ReadFull(br, buf[:])
+	n += int64(k)
+	if err != nil {
+		return n, err
+	}
+	if string(buf[:]) != magic {
+		return n, ErrFormat
+	}
+	k, err = io.ReadFull(br, buf[:])
+	n += int64(k)
+	if err != nil {
+		return n, err
+	}
+	size := binary.LittleEndian.Uint64(buf[:])
+	words := make([]uint64, numWords)
+	for i := range words {
+		k, err = io.ReadFull(br, buf[:])
+		n += int64(k)
+		if err != nil {
+			return n, err
+		}
+		words[i] = binary.LittleEndian.Uint64(buf[:])
+	}
+	s.words = words
+	if got := s.Len(); uint64(got) != size {
+		return n, fmt.Errorf("%w: header says %d elements, got %d", ErrFormat, size, got)
+	}
+	return n, nil
+}
+
+// WriteList writes the set as text, one ISSN per line in ascending order.
+func (s *Set) WriteList(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	var err error
+	s.Each(func(v issn.ISSN) bool {
+		_, err = io.WriteString(bw, v.String()+"\n")
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// InvalidError reports values in a list, that are not valid ISSN, e.g. with
+// a wrong check digit. It is returned together with the set of all valid
+// ISSN, so callers may choose to only warn about it.
+type InvalidError struct {
+	Count int    // Number of invalid values.
+	Line  int    // Line number of the first invalid value.
+	Value string // The first invalid value.
+}
+
+func (e *InvalidError) Error() string {
+	return fmt.Sprintf("bitset: %d invalid ISSN, first on line %d: %q", e.Count, e.Line, e.Value)
+}
+
+// ReadList reads a list of ISSN, one per line. Empty lines and lines
+// starting with # are skipped. If there are values, that do not parse as
+// valid ISSN, the set of valid ISSN is returned with an *InvalidError.
+func ReadList(r io.Reader) (*Set, error) {
+	var (
+		s       = New()
+		invalid InvalidError
+		lineno  int
+	)
+	s.init()
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 1<<20), 1<<24)
+	for sc.Scan() {
+		lineno++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		v, err := issn.Parse(line)
+		if err != nil {
+			if invalid.Count == 0 {
+				invalid.Line, invalid.Value = lineno, line
+			}
+			invalid.Count++
+			continue
+		}
+		s.Add(v)
+	}
+	if err := sc.Err(); err != nil {
+		return s, err
+	}
+	if invalid.Count > 0 {
+		return s, &invalid
+	}
+	return s, nil
+}
+
+// IsInvalid returns true, if err only reports invalid values in a list.
+func IsInvalid(err error) bool {
+	var ie *InvalidError
+	return errors.As(err, &ie)
+}
+
+// ReadFile reads a set from a file, which may either be in the binary format
+// or a list of ISSN, one per line. Like ReadList, it returns the set with an
+// *InvalidError for invalid values in a list.
+func ReadFile(filename string) (*Set, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	if b, err := br.Peek(len(magic)); err == nil && bytes.Equal(b, []byte(magic)) {
+		s := &Set{}
+		if _, err := s.ReadFrom(br); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return ReadList(br)
+}
+
+// WriteFile atomically writes the binary serialization of the set to a file.
+func (s *Set) WriteFile(filename string) error {
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		return err
+	}
+	return atomic.WriteFile(filename, buf.Bytes(), 0644)
+}
@@ -0,0 +1,115 @@
+package bitset
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/miku/issnlister/issn"
+)
+
+func mustParse(t *testing.T, s string) issn.ISSN {
+	t.Helper()
+	v, err := issn.Parse(s)
+	if err != nil {
+		t.Fatalf("parse %s: %v", s, err)
+	}
+	return v
+}
+
+func TestCount(t *testing.T) {
+	s := New()
+	for _, v := range []string{"0000-0019", "0000-0027", "1932-6203", "9999-9994"} {
+		s.Add(mustParse(t, v))
+	}
+	var cases = []struct {
+		lo, hi int
+		want   int
+	}{
+		{0, 10_000_000, 4},
+		{0, 2, 1},
+		{1, 2, 1},
+		{2, 3, 1},
+		{0, 3, 2},
+		{1932620, 1932621, 1},
+		{9999999, 10_000_000, 1},
+		{9999999, 20_000_000, 1},
+		{10_000_000, 10_001_000, 0},
+		{-5, -1, 0},
+		{-5, 2, 1},
+		{5, 5, 0},
+		{6, 5, 0},
+		{0, 64, 2},
+		{64, 128, 0},
+	}
+	for _, c := range cases {
+		if got := s.Count(c.lo, c.hi); got != c.want {
+			t.Errorf("Count(%d, %d): got %d, want %d", c.lo, c.hi, got, c.want)
+		}
+	}
+	if got := s.BlockCount(9999); got != 1 {
+		t.Errorf("BlockCount(9999): got %d, want 1", got)
+	}
+	if got := s.BlockCount(10000); got != 0 {
+		t.Errorf("BlockCount(10000): got %d, want 0", got)
+	}
+	if got := s.BlockCount(-1); got != 0 {
+		t.Errorf("BlockCount(-1): got %d, want 0", got)
+	}
+	if got := New().Count(0, 10); got != 0 {
+		t.Errorf("empty Count: got %d, want 0", got)
+	}
+}
+
+func TestListRoundTrip(t *testing.T) {
+	s := New()
+	for _, v := range []string{"0000-0019", "0317-8471", "1932-6203", "2434-561X", "9999-9994"} {
+		s.Add(mustParse(t, v))
+	}
+	var buf bytes.Buffer
+	if err := s.WriteList(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "0000-0019\n0317-8471\n1932-6203\n2434-561X\n9999-9994\n"
+	if buf.String() != want {
+		t.Fatalf("WriteList: got %q, want %q", buf.String(), want)
+	}
+	r, err := ReadList(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Equal(s) {
+		t.Fatalf("ReadList: got %v, want %v", r.Strings(), s.Strings())
+	}
+	if r.Count(0, 10_000_000) != 5 || r.Count(9999999, 10_000_000) != 1 {
+		t.Fatalf("Count after round trip: %d", r.Count(0, 10_000_000))
+	}
+	buf.Reset()
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var b Set
+	if _, err := b.ReadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Equal(s) {
+		t.Fatalf("ReadFrom: got %v, want %v", b.Strings(), s.Strings())
+	}
+}
+
+func TestReadListInvalid(t *testing.T) {
+	s, err := ReadList(strings.NewReader("# comment\n0000-0019\n\n0000-0018\n1932-6203\nxyz\n"))
+	ie, ok := err.(*InvalidError)
+	if !ok {
+		t.Fatalf("got %v, want *InvalidError", err)
+	}
+	if ie.Count != 2 || ie.Line != 4 || ie.Value != "0000-0018" {
+		t.Errorf("got %+v", ie)
+	}
+	if !IsInvalid(err) {
+		t.Errorf("IsInvalid: got false")
+	}
+	if s.Len() != 2 {
+		t.Errorf("got %d valid ISSN, want 2", s.Len())
+	}
+}
@@ -0,0 +1,440 @@
+// Package checker annotates values with whether they are a valid and a
+// registered ISSN. It reads plain lines, a column of TSV or CSV or a field
+// of NDJSON records, optionally finds ISSN-like tokens in free text, and
+// writes one result per value as TSV, CSV or NDJSON, together with the
+// original record, so that whole dumps can be annotated in a single pass.
+//
+// The default output is the short form of issncheck: 1 or 0 (registered or
+// not) and the normalized ISSN, or X and the value without spaces and
+// hyphens, if it is not a valid ISSN, e.g. because of a wrong check digit.
+//
+// Results in the other formats have the fields value (as found), issn
+// (normalized), valid (checksum), registered and reason, e.g.
+//
+//	0378-5955  0378-5955  1  1  registered
+//	1234-5678  1234-5678  0  0  check digit mismatch, want 9
+//	ISSN foo              0  0  invalid character
+//
+// In TSV and CSV output, the fields of the original record follow the
+// result fields, in NDJSON output the original record is kept under
+// "record". Plain lines are not repeated, unless tokens are extracted.
+package checker
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/issn"
+)
+
+// Formats for input and output.
+const (
+	Lines  = "lines" // one value per line, input only
+	Short  = "short" // 1, 0 or X and the ISSN or value, output only
+	TSV    = "tsv"
+	CSV    = "csv"
+	NDJSON = "ndjson"
+)
+
+// Reasons, besides the ones from parsing, e.g. "check digit mismatch".
+const (
+	ReasonRegistered    = "registered"
+	ReasonNotRegistered = "not registered"
+	ReasonNotFound      = "no issn found" // only with Extract
+	ReasonInvalidJSON   = "invalid json"
+)
+
+// header are the names of the result fields in TSV and CSV output.
+var header = []string{"value", "issn", "valid", "registered", "reason"}
+
+// tokenRe matches ISSN-like tokens in free text, four digits, an optional
+// dash or space, three digits and a check digit. Tokens within longer runs
+// of digits, like an ISBN, are dropped in values.
+var tokenRe = regexp.MustCompile(`[0-9]{4}(?:\s?[-‐‑‒–—]\s?|\s)?[0-9]{3}[0-9xX]`)
+
+// Config describes the input and output.
+type Config struct {
+	Input   string // Lines, TSV, CSV or NDJSON
+	Output  string // Short, TSV, CSV or NDJSON
+	Column  int    // 1-based column for TSV and CSV input
+	Field   string // dotted path for NDJSON input, e.g. issn or identifiers.issn
+	Extract bool   // find ISSN-like tokens, instead of taking the whole value
+	Header  bool   // TSV and CSV input starts with a header row
+}
+
+// DefaultConfig reads one value per line and writes the short form.
+var DefaultConfig = Config{Input: Lines, Output: Short, Column: 1, Field: "issn"}
+
+// RegisterFlags registers flags for the config on a flag set, with the
+// values of c as defaults.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Input, "i", c.Input, "input format: lines, tsv, csv or ndjson")
+	fs.StringVar(&c.Output, "o", c.Output, "output format: short, tsv, csv or ndjson")
+	fs.IntVar(&c.Column, "col", c.Column, "column with the ISSN for tsv and csv input, 1-based")
+	fs.StringVar(&c.Field, "field", c.Field, "field with the ISSN for ndjson input, nested fields separated by dots")
+	fs.BoolVar(&c.Extract, "x", c.Extract, "extract ISSN-like tokens from free text, one result per token")
+	fs.BoolVar(&c.Header, "header", c.Header, "tsv or csv input has a header row, which is passed through")
+}
+
+func (c *Config) validate() error {
+	switch c.Input {
+	case Lines, TSV, CSV, NDJSON:
+	default:
+		return fmt.Errorf("checker: unknown input format: %q", c.Input)
+	}
+	switch c.Output {
+	case Short, TSV, CSV, NDJSON:
+	default:
+		return fmt.Errorf("checker: unknown output format: %q", c.Output)
+	}
+	if c.Column < 1 {
+		return fmt.Errorf("checker: column must be at least 1, got %d", c.Column)
+	}
+	if c.Input == NDJSON && c.Field == "" {
+		return errors.New("checker: ndjson input requires a field")
+	}
+	return nil
+}
+
+// Result is the outcome for a single value.
+type Result struct {
+	Value      string `json:"value"`
+	ISSN       string `json:"issn,omitempty"` // normalized, also if the check digit is wrong
+	Valid      bool   `json:"valid"`
+	Registered bool   `json:"registered"`
+	Reason     string `json:"reason"`
+}
+
+// Check checks a single value against a set of registered ISSN.
+func Check(registered *bitset.Set, value string) Result {
+	r := Result{Value: value}
+	v, err := issn.Parse(value)
+	if err != nil {
+		r.ISSN, _ = issn.Normalize(value)
+		r.Reason = reason(err)
+		return r
+	}
+	r.ISSN, r.Valid = v.String(), true
+	if registered.Contains(v) {
+		r.Registered, r.Reason = true, ReasonRegistered
+	} else {
+		r.Reason = ReasonNotRegistered
+	}
+	return r
+}
+
+// reason turns a parse error into a short reason.
+func reason(err error) string {
+	var e *issn.Error
+	if !errors.As(err, &e) {
+		return err.Error()
+	}
+	if e.Err == issn.ErrCheckDigit {
+		return fmt.Sprintf("%v, want %c", e.Err, e.Want)
+	}
+	return e.Err.Error()
+}
+
+// Checker annotates records read from a reader.
+type Checker struct {
+	Registered *bitset.Set
+	Config     Config
+}
+
+// New returns a checker for a set of registered ISSN.
+func New(registered *bitset.Set, config Config) (*Checker, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &Checker{Registered: registered, Config: config}, nil
+}
+
+// values returns the values to check in a string, the whole (trimmed)
+// string or all ISSN-like tokens.
+func (c *Checker) values(s string) []string {
+	if !c.Config.Extract {
+		return []string{strings.TrimSpace(s)}
+	}
+	var result []string
+	for _, loc := range tokenRe.FindAllStringIndex(s, -1) {
+		if loc[0] > 0 && isDigit(s[loc[0]-1]) || loc[1] < len(s) && isDigit(s[loc[1]]) {
+			continue
+		}
+		result = append(result, s[loc[0]:loc[1]])
+	}
+	return result
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// results checks the values found in a string. With Extract, a string
+// without any ISSN-like token yields a single result, so that no record is
+// lost.
+func (c *Checker) results(s string) []Result {
+	vs := c.values(s)
+	if len(vs) == 0 {
+		return []Result{{Value: strings.TrimSpace(s), Reason: ReasonNotFound}}
+	}
+	rs := make([]Result, len(vs))
+	for i, v := range vs {
+		rs[i] = Check(c.Registered, v)
+	}
+	return rs
+}
+
+// Run reads records from r and writes one result per value to w. It
+// returns the number of values checked.
+func (c *Checker) Run(r io.Reader, w io.Writer) (n int, err error) {
+	bw := bufio.NewWriter(w)
+	out := newWriter(c.Config.Output, bw)
+	emit := func(rs []Result, record interface{}, fields []string) error {
+		for _, res := range rs {
+			if err := out.write(res, record, fields); err != nil {
+				return err
+			}
+		}
+		n += len(rs)
+		return nil
+	}
+	switch c.Config.Input {
+	case CSV:
+		err = c.runCSV(r, out, emit)
+	default:
+		err = c.runLines(r, out, emit)
+	}
+	if err != nil {
+		return n, err
+	}
+	if err := out.flush(); err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+type emitFunc func(rs []Result, record interface{}, fields []string) error
+
+// runLines handles line based input: lines, TSV and NDJSON.
+func (c *Checker) runLines(r io.Reader, out *writer, emit emitFunc) error {
+	br := bufio.NewReader(r)
+	for i := 0; ; i++ {
+		line, err := br.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch c.Config.Input {
+		case Lines:
+			// The value is the line, only extracted tokens need it.
+			var fields []string
+			if c.Config.Extract {
+				fields = []string{line}
+			}
+			if err := emit(c.results(line), line, fields); err != nil {
+				return err
+			}
+		case TSV:
+			fields := strings.Split(line, "\t")
+			if i == 0 && c.Config.Header {
+				if err := out.writeHeader(fields); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := emit(c.results(column(fields, c.Config.Column)), fields, fields); err != nil {
+				return err
+			}
+		case NDJSON:
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			var (
+				doc    interface{}
+				record interface{} = json.RawMessage(line)
+				rs     []Result
+			)
+			if err := json.Unmarshal([]byte(line), &doc); err != nil {
+				record = line
+				rs = []Result{{Reason: ReasonInvalidJSON}}
+			} else {
+				for _, v := range lookup(doc, c.Config.Field) {
+					rs = append(rs, c.results(v)...)
+				}
+				if len(rs) == 0 {
+					rs = c.results("")
+				}
+			}
+			if err := emit(rs, record, []string{line}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// runCSV handles CSV input, where a record may span several lines.
+func (c *Checker) runCSV(r io.Reader, out *writer, emit emitFunc) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	for i := 0; ; i++ {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if i == 0 && c.Config.Header {
+			if err := out.writeHeader(fields); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := emit(c.results(column(fields, c.Config.Column)), fields, fields); err != nil {
+			return err
+		}
+	}
+}
+
+// column returns the 1-based column k, or an empty string.
+func column(fields []string, k int) string {
+	if k > len(fields) {
+		return ""
+	}
+	return fields[k-1]
+}
+
+// lookup returns the string values at a dotted path in a decoded JSON
+// document. Arrays are flattened, numbers are formatted, so that an ISSN
+// like 20140827 stored as a number is found as well.
+func lookup(doc interface{}, path string) []string {
+	key, rest, nested := strings.Cut(path, ".")
+	var result []string
+	switch v := doc.(type) {
+	case []interface{}:
+		for _, e := range v {
+			result = append(result, lookup(e, path)...)
+		}
+	case map[string]interface{}:
+		e, ok := v[key]
+		if !ok {
+			return nil
+		}
+		if nested {
+			return lookup(e, rest)
+		}
+		switch w := e.(type) {
+		case string:
+			result = append(result, w)
+		case float64:
+			result = append(result, fmt.Sprintf("%08.0f", w))
+		case []interface{}:
+			for _, x := range w {
+				switch y := x.(type) {
+				case string:
+					result = append(result, y)
+				case float64:
+					result = append(result, fmt.Sprintf("%08.0f", y))
+				}
+			}
+		}
+	}
+	return result
+}
+
+// writer writes results in one of the output formats.
+type writer struct {
+	format string
+	w      *bufio.Writer
+	csv    *csv.Writer
+	enc    *json.Encoder
+}
+
+func newWriter(format string, w *bufio.Writer) *writer {
+	wr := &writer{format: format, w: w}
+	switch format {
+	case CSV:
+		wr.csv = csv.NewWriter(w)
+	case NDJSON:
+		wr.enc = json.NewEncoder(w)
+		wr.enc.SetEscapeHTML(false)
+	}
+	return wr
+}
+
+// writeHeader writes the names of the result fields, followed by the
+// header of the input. Short and NDJSON output have no header.
+func (wr *writer) writeHeader(fields []string) error {
+	row := append(append([]string{}, header...), fields...)
+	switch wr.format {
+	case TSV:
+		return wr.writeTSV(row)
+	case CSV:
+		return wr.csv.Write(row)
+	}
+	return nil
+}
+
+// write writes a result with the original record, which is kept as is in
+// NDJSON output and as fields in TSV and CSV output.
+func (wr *writer) write(r Result, record interface{}, fields []string) error {
+	switch wr.format {
+	case NDJSON:
+		return wr.enc.Encode(struct {
+			Result
+			Record interface{} `json:"record"`
+		}{r, record})
+	case Short:
+		if !r.Valid {
+			// Also for a wrong check digit, which Normalize would accept.
+			// Like the original issncheck, the value is written without
+			// spaces and hyphens.
+			return wr.writeTSV([]string{"X", shortReplacer.Replace(r.Value)})
+		}
+		return wr.writeTSV([]string{flag01(r.Registered), r.ISSN})
+	}
+	row := append([]string{r.Value, r.ISSN, flag01(r.Valid), flag01(r.Registered), r.Reason}, fields...)
+	if wr.format == CSV {
+		return wr.csv.Write(row)
+	}
+	return wr.writeTSV(row)
+}
+
+var shortReplacer = strings.NewReplacer(" ", "", "-", "")
+
+var tsvReplacer = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+
+func (wr *writer) writeTSV(row []string) error {
+	for i, f := range row {
+		if i > 0 {
+			wr.w.WriteByte('\t')
+		}
+		wr.w.WriteString(tsvReplacer.Replace(f))
+	}
+	return wr.w.WriteByte('\n')
+}
+
+func (wr *writer) flush() error {
+	if wr.csv != nil {
+		wr.csv.Flush()
+		return wr.csv.Error()
+	}
+	return nil
+}
+
+func flag01(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
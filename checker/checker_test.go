@@ -0,0 +1,28 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/issn"
+)
+
+func TestRunShort(t *testing.T) {
+	set := bitset.New()
+	set.Add(issn.MustParse("0378-5955"))
+	c, err := New(set, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	// A wrong check digit is not an ISSN, even if it normalizes.
+	in := "ISSN 0378 5955\n1932-6203\n0378-5954\n2434-561x\nab c-d\n"
+	if _, err := c.Run(strings.NewReader(in), &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "1\t0378-5955\n0\t1932-6203\nX\t03785954\n0\t2434-561X\nX\tabcd\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
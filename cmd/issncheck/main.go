@@ -1,52 +1,51 @@
 // issncheck tells you, whether an ISSN is registered or not (by using a
 // hopefully up to date list of ISSN scraped from issn.org sitemap).
 //
+// It reads one value per line and writes 1 or 0 (registered or not) and the
+// ISSN, or X and the value, if it is not a valid ISSN. It can also read a
+// column of TSV or CSV or a field of NDJSON records and write the original
+// records annotated with the normalized ISSN, checksum validity,
+// registration and a reason, see package checker.
+//
+//	$ issncheck < list.txt
+//	$ issncheck -o tsv < list.txt
+//	$ issncheck -i csv -header -col 3 -o csv < dump.csv
+//	$ issncheck -i ndjson -field identifiers.issn -o ndjson < dump.ndj
+//	$ issncheck -x < references.txt
+//
 // Note: The issn.tsv file will be temporarily copied into this folder during
 // compilation.
 package main
 
 import (
-	"bufio"
 	_ "embed"
-	"fmt"
-	"io"
+	"flag"
 	"log"
 	"os"
 	"strings"
+
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/checker"
 )
 
 //go:embed issn.tsv
 var issnlist string
 
-var issnMap = make(map[string]struct{})
-
 func main() {
-	for _, v := range strings.Split(issnlist, "\n") {
-		issnMap[v] = struct{}{}
+	config := checker.DefaultConfig
+	config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	registered, err := bitset.ReadList(strings.NewReader(issnlist))
+	if bitset.IsInvalid(err) {
+		log.Printf("embedded list: %v", err)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	c, err := checker.New(registered, config)
+	if err != nil {
+		log.Fatal(err)
 	}
-	br := bufio.NewReader(os.Stdin)
-	bw := bufio.NewWriter(os.Stdout)
-	defer bw.Flush()
-	for {
-		line, err := br.ReadString('\n')
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatal(err)
-		}
-		line = strings.TrimSpace(line)
-		line = strings.ReplaceAll(line, " ", "")
-		line = strings.ReplaceAll(line, "-", "")
-		if len(line) != 8 {
-			fmt.Printf("X\t%v\n", line)
-			continue
-		}
-		v := line[:4] + "-" + line[4:]
-		if _, ok := issnMap[v]; ok {
-			fmt.Fprintf(bw, "1\t%v\n", v)
-		} else {
-			fmt.Fprintf(bw, "0\t%v\n", v)
-		}
+	if _, err := c.Run(os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
 	}
 }
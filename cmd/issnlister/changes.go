@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/record"
+	"github.com/miku/issnlister/store"
+	log "github.com/sirupsen/logrus"
+)
+
+// Kinds of record changes, field changes are of one of the record.Field*
+// kinds.
+const (
+	RecordAdded   = "record-added"
+	RecordRemoved = "record-removed"
+)
+
+// Change is an entry in the change log between two harvests.
+type Change struct {
+	ISSN  string      `json:"issn"`
+	Type  string      `json:"type"`
+	Field string      `json:"field,omitempty"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new,omitempty"`
+}
+
+// ChangeSummary counts changes per type and per field.
+type ChangeSummary struct {
+	Old       string         `json:"old"`
+	New       string         `json:"new"`
+	OldCount  int            `json:"old_count"`
+	NewCount  int            `json:"new_count"`
+	Modified  int            `json:"modified"` // records with at least one field change
+	Unchanged int            `json:"unchanged"`
+	ByType    map[string]int `json:"by_type"`
+	ByField   map[string]int `json:"by_field"`
+}
+
+func (s *ChangeSummary) add(c Change) {
+	s.ByType[c.Type]++
+	if c.Field != "" {
+		s.ByField[c.Field]++
+	}
+}
+
+// eachRecord calls f for every record of a harvest file or a record store.
+// For a store, the latest version of each record is used, or the latest
+// version on or before a date given as FILE@2006-01-02.
+func eachRecord(spec string, f func(r *record.Record) error) error {
+	filename, date := spec, ""
+	if i := strings.LastIndex(spec, "@"); i > 0 {
+		if _, err := time.Parse("2006-01-02", spec[i+1:]); err == nil {
+			filename, date = spec[:i], spec[i+1:]
+		}
+	}
+	s, err := store.OpenReadOnly(filename)
+	switch {
+	case errors.Is(err, store.ErrFormat):
+		if date != "" {
+			return fmt.Errorf("%s: not a record store, cannot select a date", filename)
+		}
+		return eachHarvestRecord(filename, f)
+	case err != nil:
+		return err
+	}
+	defer s.Close()
+	var until time.Time
+	if date != "" {
+		t, _ := time.Parse("2006-01-02", date)
+		until = t.AddDate(0, 0, 1)
+	}
+	for _, v := range s.Keys() {
+		var ver *store.Version
+		for _, w := range s.Versions(v) {
+			if !until.IsZero() && !w.Time.Before(until) {
+				break
+			}
+			ver = &w
+		}
+		if ver == nil {
+			continue
+		}
+		body, err := s.Body(*ver)
+		if err != nil {
+			return err
+		}
+		r, err := record.DecodeISSN(body, v.String())
+		if err != nil {
+			log.Warnf("%s: %s: %v", filename, v, err)
+			continue
+		}
+		if err := f(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eachHarvestRecord calls f for every record of a harvest file, see
+// eachHarvestLine.
+func eachHarvestRecord(filename string, f func(r *record.Record) error) error {
+	return eachHarvestLine(filename, func(_ issn.ISSN, r *record.Record, _ []byte) error {
+		return f(r)
+	})
+}
+
+// compareHarvests reads the older harvest into memory, streams the newer one
+// and calls emit for every change. Records removed are reported last, in
+// ISSN order. If an ISSN occurs more than once in a harvest, its first record
+// is used, in both harvests.
+func compareHarvests(older, newer string, ignore map[string]bool, emit func(Change) error) (*ChangeSummary, error) {
+	s := &ChangeSummary{
+		Old:     older,
+		New:     newer,
+		ByType:  make(map[string]int),
+		ByField: make(map[string]int),
+	}
+	prev := make(map[string]*record.Record)
+	err := eachRecord(older, func(r *record.Record) error {
+		if _, ok := prev[r.ISSN]; !ok {
+			prev[r.ISSN] = r
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.OldCount = len(prev)
+	seen := make(map[string]bool)
+	err = eachRecord(newer, func(r *record.Record) error {
+		if seen[r.ISSN] {
+			return nil
+		}
+		seen[r.ISSN] = true
+		s.NewCount++
+		o, ok := prev[r.ISSN]
+		if !ok {
+			c := Change{ISSN: r.ISSN, Type: RecordAdded}
+			s.add(c)
+			return emit(c)
+		}
+		delete(prev, r.ISSN)
+		var modified bool
+		for _, fc := range record.Compare(o, r) {
+			if ignore[fc.Field] {
+				continue
+			}
+			modified = true
+			c := Change{ISSN: r.ISSN, Type: fc.Kind(), Field: fc.Field, Old: fc.Old, New: fc.New}
+			s.add(c)
+			if err := emit(c); err != nil {
+				return err
+			}
+		}
+		if modified {
+			s.Modified++
+		} else {
+			s.Unchanged++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	removed := make([]string, 0, len(prev))
+	for v := range prev {
+		removed = append(removed, v)
+	}
+	sort.Strings(removed)
+	for _, v := range removed {
+		c := Change{ISSN: v, Type: RecordRemoved}
+		s.add(c)
+		if err := emit(c); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// writeText writes the counts per type and per field.
+func (s *ChangeSummary) writeText(w io.Writer) error {
+	fmt.Fprintf(w, "%s: %d\n%s: %d\nmodified: %d\nunchanged: %d\n\n",
+		s.Old, s.OldCount, s.New, s.NewCount, s.Modified, s.Unchanged)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "type\tcount\t")
+	for _, k := range sortedKeys(s.ByType) {
+		fmt.Fprintf(tw, "%s\t%d\t\n", k, s.ByType[k])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(s.ByField) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "field\tcount\t")
+	for _, k := range sortedKeys(s.ByField) {
+		fmt.Fprintf(tw, "%s\t%d\t\n", k, s.ByField[k])
+	}
+	return tw.Flush()
+}
+
+// runChanges compares two harvests record by record and writes a field
+// level change log as NDJSON.
+//
+//	$ issnlister changes 2026-02.ndj.gz 2026-06.ndj.gz > changes.ndj
+//	$ issnlister changes -summary -ignore modified data.store@2026-02-16 data.store
+func runChanges(args []string) error {
+	fs := flag.NewFlagSet("changes", flag.ExitOnError)
+	var (
+		summary = fs.Bool("summary", false, "print counts per change type and field instead of changes")
+		asJSON  = fs.Bool("json", false, "print a JSON summary")
+		ignore  = fs.String("ignore", "", "comma separated fields to ignore, e.g. modified")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s changes [-summary] [-json] [-ignore FIELDS] OLD NEW\n\n", appName)
+		fmt.Fprintln(fs.Output(), "OLD and NEW are harvest files or record stores; FILE@2006-01-02 selects")
+		fmt.Fprintln(fs.Output(), "the versions of a store as of a date. Change types are record-added,")
+		fmt.Fprintln(fs.Output(), "record-removed, field-added, field-removed and field-modified.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	ignored := make(map[string]bool)
+	for _, f := range strings.Split(*ignore, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			ignored[f] = true
+		}
+	}
+	var (
+		bw   = bufio.NewWriter(os.Stdout)
+		enc  = json.NewEncoder(bw)
+		emit = enc.Encode
+	)
+	defer bw.Flush()
+	enc.SetEscapeHTML(false)
+	if *summary || *asJSON {
+		emit = func(interface{}) error { return nil }
+	}
+	s, err := compareHarvests(fs.Arg(0), fs.Arg(1), ignored, func(c Change) error { return emit(c) })
+	if err != nil {
+		return err
+	}
+	switch {
+	case *asJSON:
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	case *summary:
+		return s.writeText(bw)
+	}
+	var counts []string
+	for _, k := range sortedKeys(s.ByType) {
+		counts = append(counts, fmt.Sprintf("%s=%d", k, s.ByType[k]))
+	}
+	log.Printf("%d records modified, %d unchanged: %s", s.Modified, s.Unchanged, strings.Join(counts, " "))
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/store"
+)
+
+// titled returns a harvest line for a record with a main title.
+func titled(v, title string) string {
+	return `{"@graph": [{"@id": "resource/ISSN/` + v + `", "mainTitle": "` + title + `"}]}` + "\n"
+}
+
+// compareAll compares two harvests and returns changes formatted as
+// "issn type field old new".
+func compareAll(t *testing.T, older, newer string) ([]string, *ChangeSummary) {
+	t.Helper()
+	var got []string
+	s, err := compareHarvests(older, newer, nil, func(c Change) error {
+		if c.Field == "" {
+			got = append(got, c.ISSN+" "+c.Type)
+		} else {
+			got = append(got, fmt.Sprintf("%s %s %s %v %v", c.ISSN, c.Type, c.Field, c.Old, c.New))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got, s
+}
+
+func TestCompareHarvests(t *testing.T) {
+	var (
+		dir   = t.TempDir()
+		older = filepath.Join(dir, "2026-02.ndj")
+		newer = filepath.Join(dir, "2026-06.ndj")
+	)
+	// Duplicates in both files, the first record is used.
+	if err := os.WriteFile(older, []byte(titled("0000-0019", "A")+titled("1932-6203", "B")+
+		titled("0378-5955", "C")+titled("0000-0019", "A2")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(newer, []byte(titled("0000-0019", "A")+titled("1932-6203", "B2")+
+		titled("2434-561X", "D")+titled("1932-6203", "B")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, s := compareAll(t, older, newer)
+	want := []string{
+		"1932-6203 field-modified main_title B B2",
+		"2434-561X record-added",
+		"0378-5955 record-removed",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if s.OldCount != 3 || s.NewCount != 3 || s.Modified != 1 || s.Unchanged != 1 {
+		t.Errorf("summary: got %+v", s)
+	}
+	for k, want := range map[string]int{RecordAdded: 1, RecordRemoved: 1, "field-modified": 1} {
+		if s.ByType[k] != want {
+			t.Errorf("%s: got %d, want %d", k, s.ByType[k], want)
+		}
+	}
+}
+
+func TestCompareStoreDates(t *testing.T) {
+	var (
+		filename = filepath.Join(t.TempDir(), "data.store")
+		a        = issn.MustParse("1932-6203")
+		b        = issn.MustParse("2434-561X")
+		t0       = time.Date(2026, 2, 16, 12, 0, 0, 0, time.UTC)
+		t1       = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	)
+	s, err := store.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []struct {
+		v     issn.ISSN
+		t     time.Time
+		title string
+	}{
+		{a, t0, "B"},
+		{a, t1, "B2"},
+		{b, t1, "D"},
+	} {
+		if err := s.Put(p.v, p.t, []byte(titled(p.v.String(), p.title))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var cases = []struct {
+		older, newer string
+		want         []string
+	}{
+		{filename + "@2026-02-16", filename, []string{
+			"1932-6203 field-modified main_title B B2",
+			"2434-561X record-added",
+		}},
+		{filename + "@2026-02-15", filename + "@2026-05-31", []string{
+			"1932-6203 record-added",
+		}},
+		{filename + "@2026-06-01", filename, nil},
+	}
+	for _, c := range cases {
+		got, _ := compareAll(t, c.older, c.newer)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s %s: got %q, want %q", filepath.Base(c.older), filepath.Base(c.newer), got, c.want)
+		}
+	}
+	// A date on a harvest file is an error.
+	harvestFile := filepath.Join(t.TempDir(), "data.ndj")
+	if err := os.WriteFile(harvestFile, []byte(titled("1932-6203", "B")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := compareHarvests(harvestFile+"@2026-02-16", harvestFile, nil, func(Change) error { return nil }); err == nil {
+		t.Errorf("date on a harvest file: want error")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/checker"
+	"github.com/miku/issnlister/stringutil"
+)
+
+// runCheck annotates values read from stdin or given as arguments with
+// whether they are registered, like issncheck, but against the cached list
+// or a given snapshot.
+//
+//	$ issnlister check 0378-5955 1234-5678
+//	$ issnlister check -i ndjson -field issn -o ndjson < dump.ndj
+func runCheck(args []string) error {
+	var (
+		fs       = flag.NewFlagSet("check", flag.ExitOnError)
+		config   = checker.DefaultConfig
+		snapshot = fs.String("f", "", "check against this snapshot, a date (2006-01-02) from the cache or a file (default: latest list)")
+	)
+	config.RegisterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s check [flags] [ISSN ...]\n\n", appName)
+		fmt.Fprintln(fs.Output(), "Values are read from stdin, if none are given as arguments and stdin is")
+		fmt.Fprintln(fs.Output(), "not a terminal. Default output is 1 or 0 (registered or not) and the")
+		fmt.Fprintln(fs.Output(), "ISSN, or X and the value, if it is not a valid ISSN; -o tsv adds validity,")
+		fmt.Fprintln(fs.Output(), "reason and the input.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		// Do not wait for input on a terminal.
+		// https://stackoverflow.com/a/26567513/89391
+		stat, err := os.Stdin.Stat()
+		if err != nil {
+			return err
+		}
+		if stat.Mode()&os.ModeCharDevice != 0 {
+			fs.Usage()
+			os.Exit(1)
+		}
+	}
+	var (
+		set *bitset.Set
+		err error
+	)
+	if *snapshot != "" {
+		set, err = loadSnapshot(*snapshot)
+	} else {
+		var cacher *Cacher
+		if cacher, err = NewCacher(); err == nil {
+			set, err = cacher.Set()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	c, err := checker.New(set, config)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		_, err = c.Run(stringutil.SliceReader(fs.Args()), os.Stdout)
+	} else {
+		_, err = c.Run(os.Stdin, os.Stdout)
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/issn"
+)
+
+// DiffCount holds the number of added and removed ISSN in a range.
+type DiffCount struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// DiffSummary summarizes the changes between two snapshots.
+type DiffSummary struct {
+	Old      string               `json:"old"`
+	New      string               `json:"new"`
+	OldCount int                  `json:"old_count"`
+	NewCount int                  `json:"new_count"`
+	Added    int                  `json:"added"`
+	Removed  int                  `json:"removed"`
+	ByPrefix map[string]DiffCount `json:"by_prefix2"`
+	ByBlock  map[string]DiffCount `json:"by_block"`
+}
+
+// summarizeDiff counts the added and removed ISSN per 2-digit prefix and
+// per 4-digit block.
+func summarizeDiff(added, removed *bitset.Set) *DiffSummary {
+	s := &DiffSummary{
+		Added:    added.Len(),
+		Removed:  removed.Len(),
+		ByPrefix: make(map[string]DiffCount),
+		ByBlock:  make(map[string]DiffCount),
+	}
+	added.Each(func(v issn.ISSN) bool {
+		p, b := fmt.Sprintf("%02d", v.Block()/100), fmt.Sprintf("%04d", v.Block())
+		c := s.ByPrefix[p]
+		c.Added++
+		s.ByPrefix[p] = c
+		c = s.ByBlock[b]
+		c.Added++
+		s.ByBlock[b] = c
+		return true
+	})
+	removed.Each(func(v issn.ISSN) bool {
+		p, b := fmt.Sprintf("%02d", v.Block()/100), fmt.Sprintf("%04d", v.Block())
+		c := s.ByPrefix[p]
+		c.Removed++
+		s.ByPrefix[p] = c
+		c = s.ByBlock[b]
+		c.Removed++
+		s.ByBlock[b] = c
+		return true
+	})
+	return s
+}
+
+// writeCounts writes a table of counts, sorted by key.
+func writeCounts(w io.Writer, title string, m map[string]DiffCount) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "%s\tadded\tremoved\t\n", title)
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t\n", k, m[k].Added, m[k].Removed)
+	}
+	return tw.Flush()
+}
+
+// writeText writes the totals and the counts per prefix and block.
+func (s *DiffSummary) writeText(w io.Writer) error {
+	fmt.Fprintf(w, "%s: %d\n%s: %d\nadded: %d\nremoved: %d\n\n",
+		s.Old, s.OldCount, s.New, s.NewCount, s.Added, s.Removed)
+	if err := writeCounts(w, "prefix", s.ByPrefix); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	return writeCounts(w, "block", s.ByBlock)
+}
+
+// writeDiff writes removed ISSN prefixed with "-", then added ISSN prefixed
+// with "+", one per line.
+func writeDiff(w io.Writer, added, removed *bitset.Set) error {
+	var err error
+	removed.Each(func(v issn.ISSN) bool {
+		_, err = fmt.Fprintf(w, "-\t%s\n", v)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	added.Each(func(v issn.ISSN) bool {
+		_, err = fmt.Fprintf(w, "+\t%s\n", v)
+		return err == nil
+	})
+	return err
+}
+
+// runDiff compares two snapshots, given as dates of cached lists or
+// filenames, and reports added and removed ISSN.
+//
+//	$ issnlister diff 2026-02-16 issn.tsv
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	var (
+		summary = fs.Bool("summary", false, "print counts per 2-digit prefix and 4-digit block instead of ISSN")
+		asJSON  = fs.Bool("json", false, "print a JSON summary")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s diff [-summary] [-json] OLD NEW\n\n", appName)
+		fmt.Fprintln(fs.Output(), "OLD and NEW are snapshot dates (2006-01-02) from the cache or files.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	older, err := loadSnapshot(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	newer, err := loadSnapshot(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	var (
+		added   = newer.Difference(older)
+		removed = older.Difference(newer)
+		s       = summarizeDiff(added, removed)
+		bw      = bufio.NewWriter(os.Stdout)
+	)
+	defer bw.Flush()
+	s.Old, s.New = fs.Arg(0), fs.Arg(1)
+	s.OldCount, s.NewCount = older.Len(), newer.Len()
+	switch {
+	case *asJSON:
+		enc := json.NewEncoder(bw)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	case *summary:
+		return s.writeText(bw)
+	default:
+		return writeDiff(bw, added, removed)
+	}
+}
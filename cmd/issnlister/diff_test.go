@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		olderF = filepath.Join(dir, "old.tsv")
+		newerF = filepath.Join(dir, "new.tsv")
+	)
+	if err := os.WriteFile(olderF, []byte("0000-0019\n0000-0027\n1932-6203\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(newerF, []byte("2434-561X\n0000-0019\n1932-6203\n0001-0014\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	older, err := loadSnapshot(olderF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newer, err := loadSnapshot(newerF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, removed := newer.Difference(older), older.Difference(newer)
+
+	var sb strings.Builder
+	if err := writeDiff(&sb, added, removed); err != nil {
+		t.Fatal(err)
+	}
+	if want := "-\t0000-0027\n+\t0001-0014\n+\t2434-561X\n"; sb.String() != want {
+		t.Errorf("diff: got %q, want %q", sb.String(), want)
+	}
+
+	s := summarizeDiff(added, removed)
+	if s.Added != 2 || s.Removed != 1 {
+		t.Errorf("got %d added, %d removed, want 2, 1", s.Added, s.Removed)
+	}
+	var cases = []struct {
+		got, want map[string]DiffCount
+	}{
+		{s.ByPrefix, map[string]DiffCount{"00": {1, 1}, "24": {1, 0}}},
+		{s.ByBlock, map[string]DiffCount{"0000": {0, 1}, "0001": {1, 0}, "2434": {1, 0}}},
+	}
+	for _, c := range cases {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("got %v, want %v", c.got, c.want)
+		}
+	}
+	s.Old, s.New, s.OldCount, s.NewCount = "old.tsv", "new.tsv", older.Len(), newer.Len()
+	sb.Reset()
+	if err := s.writeText(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want := "old.tsv: 3\nnew.tsv: 4\nadded: 2\nremoved: 1\n\n" +
+		"  prefix  added  removed\n" +
+		"      00      1        1\n" +
+		"      24      1        0\n" +
+		"\n" +
+		"  block  added  removed\n" +
+		"   0000      0        1\n" +
+		"   0001      1        0\n" +
+		"   2434      1        0\n"
+	if sb.String() != want {
+		t.Errorf("summary: got\n%s\nwant\n%s", sb.String(), want)
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/issn"
+)
+
+// datedSnapshot is a snapshot file with the date it was taken.
+type datedSnapshot struct {
+	Date     time.Time
+	Filename string
+}
+
+// findSnapshots collects dated snapshots in a directory: cache day
+// directories (2006-01-02) and files with a date in their name, like
+// issn-2026-02-16.tsv. For each date, the first one found is used.
+func findSnapshots(dir string) ([]datedSnapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		result []datedSnapshot
+		seen   = make(map[time.Time]bool)
+	)
+	for _, e := range entries {
+		t, ok := issn.SnapshotDate(e.Name())
+		if !ok || seen[t] {
+			continue
+		}
+		filename := filepath.Join(dir, e.Name())
+		if e.IsDir() {
+			c := &Cacher{Directory: dir, Prefix: e.Name()}
+			filename = ""
+			for _, fn := range []string{c.SerialnumbersSetFile(), c.SerialnumbersFile()} {
+				if _, err := os.Stat(fn); err == nil {
+					filename = fn
+					break
+				}
+			}
+			if filename == "" {
+				continue
+			}
+		}
+		seen[t] = true
+		result = append(result, datedSnapshot{Date: t, Filename: filename})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Date.Before(result[j].Date) })
+	return result, nil
+}
+
+// GrowthPoint summarizes a single snapshot in a time series.
+type GrowthPoint struct {
+	Date     string         `json:"date"`
+	Filename string         `json:"filename"`
+	Count    int            `json:"count"`
+	ByPrefix map[string]int `json:"by_prefix2"`
+	Blocks   int            `json:"blocks"`
+	MaxBlock int            `json:"max_active_block"`
+	// Opened is the number of blocks populated since the previous
+	// snapshot, which were empty before.
+	Opened         int     `json:"opened"`
+	OpenedPerMonth float64 `json:"opened_per_month"`
+	days           float64 // since the first snapshot
+}
+
+// Projection extrapolates the series for a number of days.
+type Projection struct {
+	Horizon int `json:"horizon_days"`
+	// Linear fit of the max active block over time.
+	BlocksPerMonth float64 `json:"blocks_per_month"`
+	MaxBlock       int     `json:"max_active_block"`
+	// Poisson model of block openings, with an 80% interval.
+	OpeningRate float64 `json:"openings_per_month"`
+	OpenedLow   int     `json:"opened_low"`
+	OpenedHigh  int     `json:"opened_high"`
+	NextOpening string  `json:"next_opening"` // expected date of the next new block
+	ISSNPerYear float64 `json:"issn_per_year"`
+	ProbeMin    string  `json:"probe_prefix_min"`
+	ProbeMax    string  `json:"probe_prefix_max"`
+}
+
+// Growth is a time series of snapshots with a projection.
+type Growth struct {
+	Points     []*GrowthPoint `json:"points"`
+	Projection *Projection    `json:"projection,omitempty"`
+}
+
+// growthPoint summarizes a set and returns its block counts. Opened
+// blocks are counted against the block counts of the previous snapshot,
+// if any.
+func growthPoint(s *bitset.Set, prev []int) (*GrowthPoint, []int) {
+	var (
+		p      = &GrowthPoint{Count: s.Len(), ByPrefix: make(map[string]int)}
+		counts = make([]int, 10000)
+	)
+	for b := range counts {
+		n := s.BlockCount(b)
+		counts[b] = n
+		if n == 0 {
+			continue
+		}
+		p.Blocks++
+		p.ByPrefix[fmt.Sprintf("%02d", b/100)] += n
+		if b/100 != legacyPrefix {
+			p.MaxBlock = b
+		}
+		if prev != nil && prev[b] == 0 {
+			p.Opened++
+		}
+	}
+	return p, counts
+}
+
+// computeGrowth reads the snapshots one by one.
+func computeGrowth(snapshots []datedSnapshot) (*Growth, error) {
+	var (
+		g    = &Growth{}
+		prev []int
+	)
+	for i, ds := range snapshots {
+		s, err := readSet(ds.Filename)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ds.Filename, err)
+		}
+		var p *GrowthPoint
+		p, prev = growthPoint(s, prev)
+		p.Date, p.Filename = ds.Date.Format("2006-01-02"), ds.Filename
+		p.days = ds.Date.Sub(snapshots[0].Date).Hours() / 24
+		if i > 0 {
+			if d := p.days - g.Points[i-1].days; d > 0 {
+				p.OpenedPerMonth = float64(p.Opened) / d * 30
+			}
+		}
+		g.Points = append(g.Points, p)
+	}
+	return g, nil
+}
+
+// linearFit returns the least squares slope and intercept of y over x.
+func linearFit(x, y []float64) (slope, intercept float64) {
+	n := float64(len(x))
+	var sx, sy, sxx, sxy float64
+	for i := range x {
+		sx += x[i]
+		sy += y[i]
+		sxx += x[i] * x[i]
+		sxy += x[i] * y[i]
+	}
+	if d := n*sxx - sx*sx; d != 0 {
+		slope = (n*sxy - sx*sy) / d
+	}
+	return slope, (sy - slope*sx) / n
+}
+
+// poissonQuantile returns the smallest k with P(N ≤ k) ≥ q.
+func poissonQuantile(mu, q float64) int {
+	pmf := math.Exp(-mu)
+	cdf := pmf
+	k := 0
+	for cdf < q && k < 100000 {
+		k++
+		pmf *= mu / float64(k)
+		cdf += pmf
+	}
+	return k
+}
+
+// project extrapolates the series horizon days past the last snapshot.
+// The suggested probe range starts a year's worth of openings before the
+// highest active block and ends at the upper bound of the projection.
+func (g *Growth) project(horizon int) *Projection {
+	if len(g.Points) < 2 {
+		return nil
+	}
+	var (
+		first, last = g.Points[0], g.Points[len(g.Points)-1]
+		xs, maxs    []float64
+		counts      []float64
+		opened      int
+	)
+	for i, p := range g.Points {
+		xs = append(xs, p.days)
+		maxs = append(maxs, float64(p.MaxBlock))
+		counts = append(counts, float64(p.Count))
+		if i > 0 {
+			opened += p.Opened
+		}
+	}
+	span := last.days - first.days
+	if span <= 0 {
+		return nil
+	}
+	var (
+		slope, icpt = linearFit(xs, maxs)
+		cslope, _   = linearFit(xs, counts)
+		rate        = float64(opened) / span // per day
+		h           = float64(horizon)
+		pr          = &Projection{
+			Horizon:        horizon,
+			BlocksPerMonth: slope * 30,
+			MaxBlock:       int(math.Round(icpt + slope*(last.days+h))),
+			OpeningRate:    rate * 30,
+			OpenedLow:      poissonQuantile(rate*h, 0.1),
+			OpenedHigh:     poissonQuantile(rate*h, 0.9),
+			ISSNPerYear:    cslope * 365,
+		}
+	)
+	pr.MaxBlock = max(pr.MaxBlock, last.MaxBlock)
+	lastDate, _ := time.Parse("2006-01-02", last.Date)
+	if rate > 0 {
+		pr.NextOpening = lastDate.Add(time.Duration(24/rate) * time.Hour).Format("2006-01-02")
+	}
+	pr.ProbeMin = fmt.Sprintf("%04d", max(0, last.MaxBlock-int(math.Ceil(rate*365))))
+	pr.ProbeMax = fmt.Sprintf("%04d", min(9999, max(pr.MaxBlock, last.MaxBlock+pr.OpenedHigh)))
+	return pr
+}
+
+// writeText writes the series, counts per band and the projection.
+func (g *Growth) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "date\tissn\tadded\tblocks\tmax block\topened\topened/month\t")
+	for i, p := range g.Points {
+		var added int
+		if i > 0 {
+			added = p.Count - g.Points[i-1].Count
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%+d\t%d\t%04d\t%d\t%.1f\t\n",
+			p.Date, p.Count, added, p.Blocks, p.MaxBlock, p.Opened, p.OpenedPerMonth)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(g.Points) > 1 {
+		first, last := g.Points[0], g.Points[len(g.Points)-1]
+		keys := sortedKeys(first.ByPrefix)
+		for _, k := range sortedKeys(last.ByPrefix) {
+			if _, ok := first.ByPrefix[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(tw, "prefix\t%s\t%s\tchange\t\n", first.Date, last.Date)
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%+d\t\n", k, first.ByPrefix[k], last.ByPrefix[k],
+				last.ByPrefix[k]-first.ByPrefix[k])
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if pr := g.Projection; pr != nil {
+		fmt.Fprintf(w, "\nprojection for %d days:\n", pr.Horizon)
+		fmt.Fprintf(w, "  issn per year: %.0f\n", pr.ISSNPerYear)
+		fmt.Fprintf(w, "  linear: %.1f blocks/month, max active block %04d\n", pr.BlocksPerMonth, pr.MaxBlock)
+		fmt.Fprintf(w, "  poisson: %.1f openings/month, %d-%d new blocks (80%%)", pr.OpeningRate, pr.OpenedLow, pr.OpenedHigh)
+		if pr.NextOpening != "" {
+			fmt.Fprintf(w, ", next around %s", pr.NextOpening)
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "  probe: issnprobe -mode frontier -prefix-min %s -prefix-max %s\n", pr.ProbeMin, pr.ProbeMax)
+	}
+	return nil
+}
+
+// runGrowth builds a time series from dated snapshots in a directory and
+// projects the frontier forward.
+//
+//	$ issnlister growth ~/.cache/issnlister
+//	$ issnlister growth -horizon 365 -json archive/
+func runGrowth(args []string) error {
+	fs := flag.NewFlagSet("growth", flag.ExitOnError)
+	var (
+		asJSON  = fs.Bool("json", false, "print the series as JSON")
+		horizon = fs.Int("horizon", 180, "project this many days past the last snapshot")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s growth [-json] [-horizon DAYS] [DIR]\n\n", appName)
+		fmt.Fprintln(fs.Output(), "DIR contains cache day directories (2006-01-02) or dated lists, like")
+		fmt.Fprintln(fs.Output(), "issn-2026-02-16.tsv; defaults to the cache directory.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	dir := *cacheDir
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		dir = fs.Arg(0)
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+	snapshots, err := findSnapshots(dir)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("no dated snapshots in %s", dir)
+	}
+	g, err := computeGrowth(snapshots)
+	if err != nil {
+		return err
+	}
+	g.Projection = g.project(*horizon)
+	bw := bufio.NewWriter(os.Stdout)
+	defer bw.Flush()
+	if *asJSON {
+		enc := json.NewEncoder(bw)
+		enc.SetIndent("", "  ")
+		return enc.Encode(g)
+	}
+	return g.writeText(bw)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miku/issnlister/issn"
+)
+
+// writeBlocks writes a list with one ISSN in each of the given blocks.
+func writeBlocks(t *testing.T, filename string, blocks ...int) {
+	t.Helper()
+	var sb strings.Builder
+	for _, b := range blocks {
+		sb.WriteString(issn.FromPrefix(b*1000).String() + "\n")
+	}
+	if err := os.WriteFile(filename, []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func blockRange(lo, hi int) []int {
+	var result []int
+	for b := lo; b <= hi; b++ {
+		result = append(result, b)
+	}
+	return result
+}
+
+func TestGrowth(t *testing.T) {
+	dir := t.TempDir()
+	// Three blocks opened every 30 days, the legacy pocket is ignored.
+	writeBlocks(t, filepath.Join(dir, "issn-2026-01-01.tsv"), append(blockRange(0, 9), 8750)...)
+	writeBlocks(t, filepath.Join(dir, "issn-2026-01-31.tsv"), append(blockRange(0, 12), 8750)...)
+	writeBlocks(t, filepath.Join(dir, "issn-2026-03-02.tsv"), append(blockRange(0, 15), 8750)...)
+	writeBlocks(t, filepath.Join(dir, "issn.tsv"), 0)
+	snapshots, err := findSnapshots(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 3 {
+		t.Fatalf("got %d snapshots, want 3", len(snapshots))
+	}
+	g, err := computeGrowth(snapshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []struct {
+		count, maxBlock, opened int
+		perMonth                float64
+	}{
+		{11, 9, 0, 0},
+		{14, 12, 3, 3},
+		{17, 15, 3, 3},
+	} {
+		p := g.Points[i]
+		if p.Count != want.count || p.MaxBlock != want.maxBlock || p.Opened != want.opened || math.Abs(p.OpenedPerMonth-want.perMonth) > 1e-9 {
+			t.Errorf("%s: got count %d, max block %d, opened %d (%.2f/month), want %+v",
+				p.Date, p.Count, p.MaxBlock, p.Opened, p.OpenedPerMonth, want)
+		}
+	}
+	pr := g.project(30)
+	if pr == nil {
+		t.Fatal("no projection")
+	}
+	if math.Abs(pr.BlocksPerMonth-3) > 1e-9 || math.Abs(pr.OpeningRate-3) > 1e-9 || math.Abs(pr.ISSNPerYear-36.5) > 1e-9 {
+		t.Errorf("rates: got %+v", pr)
+	}
+	// Poisson with mean 3 over 30 days: 1-5 openings.
+	if pr.MaxBlock != 18 || pr.OpenedLow != 1 || pr.OpenedHigh != 5 {
+		t.Errorf("projection: got max block %d, opened %d-%d, want 18, 1-5", pr.MaxBlock, pr.OpenedLow, pr.OpenedHigh)
+	}
+	if pr.NextOpening != "2026-03-12" {
+		t.Errorf("next opening: got %s, want 2026-03-12", pr.NextOpening)
+	}
+	if pr.ProbeMin != "0000" || pr.ProbeMax != "0020" {
+		t.Errorf("probe range: got %s-%s, want 0000-0020", pr.ProbeMin, pr.ProbeMax)
+	}
+}
+
+func TestLinearFit(t *testing.T) {
+	slope, icpt := linearFit([]float64{0, 1, 2, 3}, []float64{1, 3, 5, 7})
+	if math.Abs(slope-2) > 1e-9 || math.Abs(icpt-1) > 1e-9 {
+		t.Errorf("got %v, %v, want 2, 1", slope, icpt)
+	}
+	if slope, icpt := linearFit([]float64{1, 1}, []float64{2, 4}); slope != 0 || icpt != 3 {
+		t.Errorf("constant x: got %v, %v, want 0, 3", slope, icpt)
+	}
+}
+
+func TestPoissonQuantile(t *testing.T) {
+	var cases = []struct {
+		mu, q float64
+		want  int
+	}{
+		{0, 0.9, 0},
+		{3, 0.1, 1},
+		{3, 0.5, 3},
+		{3, 0.9, 5},
+		{100, 0.5, 100},
+	}
+	for _, c := range cases {
+		if got := poissonQuantile(c.mu, c.q); got != c.want {
+			t.Errorf("poissonQuantile(%v, %v): got %d, want %d", c.mu, c.q, got, c.want)
+		}
+	}
+}
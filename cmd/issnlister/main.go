@@ -3,20 +3,26 @@
 // Sitemap contains about 40 sub sitemaps, each with 50000 links. Cache all
 // sitemaps, maybe versioned and generate list on demand from cache.
 //
+// As of 04/2026 the sitemap is gone, the list can be generated from other
+// sources with -S, e.g. a previous snapshot, a harvest or the issnprobe cache:
+//
+//	$ issnlister -S snapshot:issn.tsv -S probecache:$HOME/.cache/issnprobe -l
+//
 // Notes:
 //
 // Sometimes, a supposedly JSON response comes back as XML; it's weird and rare
-// and I haven't been able to reproduce.
+// and I haven't been able to reproduce. Such responses are converted to JSON-LD
+// and counted, see package sniff.
 //
 // The link https://portal.issn.org/resource/ISSN/0874-2308?format=json came
 // back as 404, but it's there, right?
 package main
 
 import (
-	"bufio"
 	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
-	"encoding/xml"
 	"flag"
 	"fmt"
 	"io"
@@ -26,27 +32,30 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
-	"sort"
 	"strings"
 	"time"
 
 	"github.com/adrg/xdg"
-	"github.com/miku/clam"
 	"github.com/miku/issnlister/atomic"
-	"github.com/miku/issnlister/lines"
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/harvest"
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/probecache"
+	"github.com/miku/issnlister/sniff"
+	"github.com/miku/issnlister/store"
 	"github.com/miku/issnlister/stringutil"
 	"github.com/miku/parallel"
 	"github.com/sethgrid/pester"
 	log "github.com/sirupsen/logrus"
-	"github.com/vmihailenco/msgpack"
 )
 
 const (
 	appName    = "issnlister"
 	appVersion = "0.1.1"
 
-	StatusOK   = "ok"
-	StatusMiss = "xx"
+	// maxAttempts is the number of times a single link is requested, before
+	// it is recorded as failed.
+	maxAttempts = 10
 )
 
 var (
@@ -63,45 +72,64 @@ var (
 	userAgent       = flag.String("ua", defaultUserAgent, "set user agent")
 	showVersion     = flag.Bool("version", false, "show version")
 	continueHarvest = flag.String("c", "", "continue harvest into a given file, implies -m")
-	validate        = flag.Bool("k", false, "validate issn or list of issn (read from stdin)")
+	validate        = flag.Bool("k", false, "validate issn or list of issn (read from stdin), same as the check subcommand; must be the last flag, the rest are check flags and values")
 	cleanCache      = flag.Bool("C", false, "clean cache")
+	storeFile       = flag.String("store", "", "with -m, harvest into this record store instead of stdout, adding a version per ISSN")
+	refreshFile     = flag.String("refresh", "", "incremental harvest: carry over records fetched within -max-age from this harvest or store, fetch only older and new ISSN, implies -m")
+	maxAge          = flag.Duration("max-age", 30*24*time.Hour, "with -refresh, maximum age of records to carry over")
+	failureFile     = flag.String("F", "", "append permanently failed downloads to this file (default: harvest file with .failed suffix or failed.ndjson in the cache)")
+
+	sources stringutil.StringSlice
+
+	// failures records links, that could not be fetched; used by fetch.
+	failures *harvest.FailureLog
+	// responses counts the kinds of response bodies seen by fetch.
+	responses sniff.Counter
+	// refresh is the earlier harvest of an incremental refresh; used by
+	// fetch for conditional requests.
+	refresh *refreshSource
+	// validators records ETag, Last-Modified and body hash of fetched
+	// records, for conditional requests in a later refresh; used by fetch.
+	validators *harvest.ValidatorLog
 )
 
-// Sitemapindex was generated 2019-09-28 18:56:12 by tir on sol.
-type Sitemapindex struct {
-	XMLName xml.Name `xml:"sitemapindex"`
-	Text    string   `xml:",chardata"`
-	Xmlns   string   `xml:"xmlns,attr"`
-	Sitemap []struct {
-		Text    string `xml:",chardata"`
-		Loc     string `xml:"loc"`     // https://portal.issn.org/s...
-		Lastmod string `xml:"lastmod"` // 2019-09-27, 2019-09-27, 2...
-	} `xml:"sitemap"`
+// subcommands are run with the remaining arguments, e.g. issnlister diff a b.
+var subcommands = map[string]func(args []string) error{
+	"changes":      runChanges,
+	"check":        runCheck,
+	"diff":         runDiff,
+	"growth":       runGrowth,
+	"mapping":      runMapping,
+	"retry-failed": runRetryFailed,
+	"stats":        runStats,
+	"store":        runStore,
 }
 
-// Urlset was generated 2019-09-28 18:58:13 by tir on sol.
-type Urlset struct {
-	XMLName xml.Name `xml:"urlset"`
-	Text    string   `xml:",chardata"`
-	Xmlns   string   `xml:"xmlns,attr"`
-	Xhtml   string   `xml:"xhtml,attr"`
-	URL     []struct {
-		Text string `xml:",chardata"`
-		Loc  string `xml:"loc"` // https://portal.issn.org/r...
-		Link []struct {
-			Text     string `xml:",chardata"`
-			Rel      string `xml:"rel,attr"`
-			Hreflang string `xml:"hreflang,attr"`
-			Href     string `xml:"href,attr"`
-		} `xml:"link"`
-		Lastmod    string `xml:"lastmod"`    // 2010-03-23, 2004-06-09, 2...
-		Changefreq string `xml:"changefreq"` // monthly, monthly, monthly...
-		Priority   string `xml:"priority"`   // 0.8, 0.8, 0.8, 0.8, 0.8, ...
-	} `xml:"url"`
+func init() {
+	flag.Var(&sources, "S", "ISSN source as kind:location, kind is one of sitemap, snapshot, harvest, probecache (repeatable, default: sitemap from -s)")
+}
+
+// splitCheckArgs splits the command line after -k; the rest are flags and
+// values of the checker, which has a flag set of its own, so
+// issnlister -k -i csv reads CSV and does not set the ignore file.
+func splitCheckArgs(args []string) (main, check []string) {
+	for i, a := range args {
+		if a == "--" {
+			break
+		}
+		if _, ok := subcommands[a]; ok {
+			break
+		}
+		if a == "-k" || a == "--k" {
+			return args[:i+1], args[i+1:]
+		}
+	}
+	return args, nil
 }
 
 func main() {
-	flag.Parse()
+	args, checkArgs := splitCheckArgs(os.Args[1:])
+	flag.CommandLine.Parse(args)
 	if *showVersion {
 		fmt.Printf("%s %s\n", appName, appVersion)
 		os.Exit(0)
@@ -123,7 +151,16 @@ func main() {
 		fmt.Fprintf(os.Stderr, "issnlister: cleaned up %0.2fMB\n", float64(size)/1048576)
 		os.Exit(0)
 	}
-	cacher := NewCacher()
+	if cmd, ok := subcommands[flag.Arg(0)]; ok {
+		if err := cmd(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+	cacher, err := NewCacher()
+	if err != nil {
+		log.Fatal(err)
+	}
 	switch {
 	case *list:
 		issns, err := cacher.List()
@@ -134,108 +171,102 @@ func main() {
 			fmt.Println(issn)
 		}
 	case *validate:
-		set, err := cacher.Set()
-		if err != nil {
+		if err := runCheck(checkArgs); err != nil {
 			log.Fatal(err)
 		}
-		stat, err := os.Stdin.Stat()
-		if err != nil {
-			log.Fatal(err)
-		}
-		// https://stackoverflow.com/a/26567513/89391
-		if (stat.Mode() & os.ModeCharDevice) == 0 {
-			br := bufio.NewReader(os.Stdin)
-			for {
-				line, err := br.ReadString('\n')
-				if err == io.EOF {
-					break
-				}
-				if err != nil {
-					log.Fatal(err)
-				}
-				v := strings.TrimSpace(line)
-				if len(v) == 8 {
-					v = v[:4] + "-" + v[4:]
-				}
-				_, ok := set[v]
-				status := StatusOK
-				if !ok {
-					status = StatusMiss
-				}
-				fmt.Printf("%s\t%s\n", v, status)
-			}
-		} else {
-			// Assume issnlister -c XXXX-XXXX ...
-			for _, arg := range flag.Args() {
-				v := strings.TrimSpace(arg)
-				if len(v) == 8 {
-					v = v[:4] + "-" + v[4:]
-				}
-				_, ok := set[v]
-				status := StatusOK
-				if !ok {
-					status = StatusMiss
-				}
-				fmt.Printf("%s\t%s\n", v, status)
-			}
-		}
 
-	case *continueHarvest != "":
-		// -c implies -m
+	case *continueHarvest != "" || *refreshFile != "":
+		// -c and -refresh imply -m
 		*dump = true
 		fallthrough
 	case *dump:
-		var output io.Writer = os.Stdout
+		var (
+			output   io.Writer = os.Stdout
+			ignore             = bitset.New()
+			outStore *store.Store
+			inPlace  bool // Refreshing a store in place, nothing to carry over.
+		)
 		log.Printf("downloading public metadata")
 		if *continueHarvest != "" {
 			if *ignoreFile != "" {
 				log.Fatal("use either -c or -i, not both (-c will generate an ignore file implicitly)")
 			}
+			if *refreshFile != "" && sameFile(*continueHarvest, *refreshFile) {
+				log.Fatal("-refresh needs a new file for -c")
+			}
+			// Truncate after the last complete record and find all already
+			// harvested ISSN, using the journal, if there is one.
 			var err error
-			// Remove the last line from file, which might be partial. This is
-			// inplace, but that's ok.
-			if err = clam.Run(`touch "{{ previous }}" && sed -i '$ d' "{{ previous }}"`, clam.Map{"previous": *continueHarvest}); err != nil {
+			if ignore, err = harvest.Resume(*continueHarvest); err != nil {
 				log.Fatal(err)
 			}
-			// Find all already harvested ISSN and generate temporary ignore file, brittle regex.
-			command := fmt.Sprintf(`LC_ALL=C grep -Eo '"@id":"resource/ISSN/[^"#]*' "{{ previous }}" | cut -d '/' -f 3 | LC_ALL=C sort -u > {{ output }}`)
-			*ignoreFile, err = clam.RunOutput(command, clam.Map{"previous": *continueHarvest})
+			w, err := harvest.NewWriter(*continueHarvest)
 			if err != nil {
 				log.Fatal(err)
 			}
-			defer func() {
-				os.Remove(*ignoreFile)
-			}()
-			f, err := os.OpenFile(*continueHarvest, os.O_APPEND|os.O_RDWR|os.O_CREATE, 0644)
-			if err != nil {
+			output = w
+		}
+		if *storeFile != "" {
+			if *continueHarvest != "" {
+				log.Fatal("use either -c or -store, not both")
+			}
+			var err error
+			if outStore, err = store.Open(*storeFile); err != nil {
+				log.Fatal(err)
+			}
+			output = harvest.NewStoreWriter(outStore)
+			inPlace = *refreshFile != "" && sameFile(*storeFile, *refreshFile)
+		}
+		if *ignoreFile != "" {
+			var err error
+			if ignore, err = readSet(*ignoreFile); err != nil {
 				log.Fatal(err)
 			}
-			defer f.Close()
-			output = f
 		}
 		issns, err := cacher.List()
 		if err != nil {
 			log.Fatal(err)
 		}
-		if *ignoreFile != "" {
-			ignoreList, err := lines.FromFile(*ignoreFile)
-			if err != nil {
+		if n := ignore.Len(); n > 0 {
+			log.Printf("%d to ignore", n)
+			var filtered []string
+			for _, v := range issns {
+				if ignore.ContainsString(v) {
+					continue
+				}
+				filtered = append(filtered, v)
+			}
+			log.Printf("started with %d issn", len(issns))
+			issns = filtered
+		}
+		if *refreshFile != "" {
+			if refresh, err = readRefreshSource(*refreshFile, *maxAge); err != nil {
 				log.Fatal(err)
 			}
-			log.Printf("%d to ignore", len(ignoreList))
-
-			ignoreSet := stringutil.NewStringSet()
-			for _, v := range ignoreList {
-				ignoreSet.Add(v)
+			listed := bitset.New()
+			for _, v := range issns {
+				if w, err := issn.Parse(v); err == nil {
+					listed.Add(w)
+				}
+			}
+			keep := refresh.Fresh.Intersection(listed)
+			log.Printf("%s: %d records, %d fetched within %s, %d not listed anymore",
+				*refreshFile, refresh.All.Len(), refresh.Fresh.Len(), *maxAge,
+				refresh.All.Difference(listed).Len())
+			if !inPlace {
+				n, err := refresh.CarryOver(output, outStore, keep.Clone(), time.Time{})
+				if err != nil {
+					log.Fatal(err)
+				}
+				log.Printf("carried over %d records", n)
 			}
 			var filtered []string
-			for _, issn := range issns {
-				if ignoreSet.Contains(issn) {
+			for _, v := range issns {
+				if keep.ContainsString(v) {
 					continue
 				}
-				filtered = append(filtered, issn)
+				filtered = append(filtered, v)
 			}
-			log.Printf("started with %d issn", len(issns))
 			issns = filtered
 		}
 		// Turn list of issn into list of links.
@@ -244,13 +275,77 @@ func main() {
 		for i := 0; i < len(links); i++ {
 			links[i] = fmt.Sprintf("https://portal.issn.org/resource/ISSN/%s?format=json", issns[i])
 		}
+		if *failureFile == "" {
+			*failureFile = filepath.Join(cacher.SitemapDir(), "failed.ndjson")
+			if *continueHarvest != "" {
+				*failureFile = *continueHarvest + ".failed"
+			}
+		}
+		if failures, err = harvest.OpenFailureLog(*failureFile); err != nil {
+			log.Fatal(err)
+		}
+		var validatorFile string
+		switch {
+		case *continueHarvest != "":
+			validatorFile = harvest.ValidatorsFile(*continueHarvest)
+		case *storeFile != "":
+			validatorFile = harvest.ValidatorsFile(*storeFile)
+		}
+		if validatorFile != "" {
+			if validators, err = harvest.OpenValidatorLog(validatorFile); err != nil {
+				log.Fatal(err)
+			}
+		}
 		log.Printf("attempting to download %d links", len(links))
+		started := time.Now()
 		proc := parallel.NewProcessor(stringutil.SliceReader(links), output, fetch)
 		proc.BatchSize = *batchSize
 		proc.NumWorkers = *numWorkers
 		if err := proc.Run(); err != nil {
 			log.Fatal(err)
 		}
+		if refresh != nil {
+			// Records not modified since the last fetch are carried over,
+			// also into a store refreshed in place, to record the check.
+			n, err := refresh.CarryOverUnchanged(output, outStore)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("%d records not modified", n)
+		}
+		if refresh != nil && !inPlace {
+			// Keep the previous version of records, that failed to refresh,
+			// so the result stays complete.
+			n, err := refresh.CarryOverFailed(output, outStore, *failureFile, started)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if n > 0 {
+				log.Printf("kept %d previous records, that could not be refreshed", n)
+			}
+		}
+		if c, ok := output.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err := failures.Close(); err != nil {
+			log.Fatal(err)
+		}
+		if validators != nil {
+			if err := validators.Close(); err != nil {
+				log.Fatal(err)
+			}
+			if err := harvest.CompactValidators(validatorFile); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if n := responses.Anomalies(); n > 0 {
+			log.Printf("%d anomalous responses (%s)", n, &responses)
+		}
+		if n := failures.Len(); n > 0 {
+			log.Printf("%d links failed, see %s, rerun with: %s retry-failed -F %s", n, *failureFile, appName, *failureFile)
+		}
 	}
 }
 
@@ -272,15 +367,33 @@ func DirSize(path string) (int64, error) {
 type Cacher struct {
 	Directory string
 	Prefix    string
-	Locs      []string // Sitemap locations.
+	Sources   []Source // Sources to merge into a list of ISSN.
+	Key       string   // Identifies the sources, if not the default sitemap.
 }
 
-// NewCacher returns a Cacher with a default prefix (changing per day).
-func NewCacher() *Cacher {
-	return &Cacher{
+// NewCacher returns a Cacher with a default prefix (changing per day). If no
+// sources are given on the command line, the legacy sitemap is used.
+func NewCacher() (*Cacher, error) {
+	c := &Cacher{
 		Directory: *cacheDir,
 		Prefix:    time.Now().Format("2006-01-02"),
 	}
+	if len(sources) == 0 {
+		c.Sources = []Source{&SitemapSource{URL: *sitemapIndex, Dir: c.SitemapDir()}}
+		return c, nil
+	}
+	for _, spec := range sources {
+		src, err := ParseSource(spec, c.SitemapDir())
+		if err != nil {
+			return nil, err
+		}
+		c.Sources = append(c.Sources, src)
+	}
+	// Lists merged from other sources are cached separately, so a run with
+	// different sources on the same day does not get a stale list.
+	h := sha1.Sum([]byte(strings.Join(sources, "\n")))
+	c.Key = hex.EncodeToString(h[:4])
+	return c, nil
 }
 
 // SitemapDir returns the directory to cache the sitemap.
@@ -288,190 +401,71 @@ func (c *Cacher) SitemapDir() string {
 	return filepath.Join(c.Directory, c.Prefix)
 }
 
-// SitemapFile returns the filename for the global sitemap and creates
-// necessary subdirectories to hold the file.
-func (c *Cacher) SitemapFile() string {
-	return filepath.Join(c.SitemapDir(), "sitemap.xml")
-}
-
 // SerialnumbersFile return the location of the issn list cache file.
 func (c *Cacher) SerialnumbersFile() string {
-	return filepath.Join(c.SitemapDir(), "issnlist.tsv")
+	return filepath.Join(c.SitemapDir(), c.keyed("issnlist", ".tsv"))
 }
 
 // SerialnumbersSetFile returns the filename of the serialized set of issns.
 func (c *Cacher) SerialnumbersSetFile() string {
-	return filepath.Join(c.SitemapDir(), "issns.msgp")
-}
-
-// fetchSitemapIndex downloads and caches the main sitemap file.
-func (c *Cacher) fetchSitemapIndex() error {
-	if err := ensureDir(c.SitemapDir()); err != nil {
-		return err
-	}
-	if _, err := os.Stat(c.SitemapFile()); err == nil {
-		return nil
-	}
-	resp, err := pester.Get(*sitemapIndex)
-	if err != nil {
-		return err
-	}
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("failed to fetch sitemap: %d", resp.StatusCode)
-	}
-	defer resp.Body.Close()
-	return atomic.WriteFileReader(c.SitemapFile(), resp.Body, 0644)
-}
-
-// fetchSitemaps tries to fetch all linked sitemaps (currently around 40).
-func (c *Cacher) fetchSitemaps() error {
-	if err := c.findLocations(); err != nil {
-		return err
-	}
-	for _, loc := range c.Locs {
-		// https://portal.issn.org/sitemap6.xml
-		parts := strings.Split(loc, "/")
-		filename := filepath.Join(c.SitemapDir(), parts[len(parts)-1])
-		if _, err := os.Stat(filename); err == nil {
-			log.Printf("%s cached at %s", loc, filename)
-			continue
-		}
-		log.Println(loc)
-		resp, err := pester.Get(loc)
-		if err != nil {
-			return err
-		}
-		if resp.StatusCode >= 400 {
-			return fmt.Errorf("failed to fetch sitemap at %s: %d", loc, resp.StatusCode)
-		}
-		defer resp.Body.Close()
-		if err := atomic.WriteFileReader(filename, resp.Body, 0644); err != nil {
-			return err
-		}
-	}
-	return nil
+	return filepath.Join(c.SitemapDir(), c.keyed("issns", ".bits"))
 }
 
-// findLocations populates the linked sitemaps.
-func (c *Cacher) findLocations() error {
-	if err := c.fetchSitemapIndex(); err != nil {
-		return err
+// keyed returns a cache filename, with the key of the sources, if set.
+func (c *Cacher) keyed(name, ext string) string {
+	if c.Key == "" {
+		return name + ext
 	}
-	f, err := os.Open(c.SitemapFile())
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	dec := xml.NewDecoder(f)
-	var si Sitemapindex
-	if err := dec.Decode(&si); err != nil {
-		return err
-	}
-	for _, sm := range si.Sitemap {
-		c.Locs = append(c.Locs, sm.Loc)
-	}
-	return nil
+	return name + "-" + c.Key + ext
 }
 
 // Set returns a set of ISSN, cached for performance.
-func (c *Cacher) Set() (map[string]struct{}, error) {
+func (c *Cacher) Set() (*bitset.Set, error) {
 	if _, err := os.Stat(c.SerialnumbersSetFile()); err != nil {
 		log.Printf("caching set at %v", c.SerialnumbersSetFile())
 		list, err := c.List()
 		if err != nil {
 			return nil, err
 		}
-		m := make(map[string]struct{})
-		for _, issn := range list {
-			m[issn] = struct{}{}
-		}
-		b, err := msgpack.Marshal(m)
-		if err != nil {
-			return nil, err
+		set := bitset.New()
+		for _, v := range list {
+			if w, err := issn.Parse(v); err == nil {
+				set.Add(w)
+			}
 		}
-		if err := ioutil.WriteFile(c.SerialnumbersSetFile(), b, 0644); err != nil {
+		if err := set.WriteFile(c.SerialnumbersSetFile()); err != nil {
 			return nil, err
 		}
+		return set, nil
 	}
-	b, err := ioutil.ReadFile(c.SerialnumbersSetFile())
-	if err != nil {
-		return nil, err
-	}
-	v := make(map[string]struct{})
-	if err := msgpack.Unmarshal(b, &v); err != nil {
-		return nil, err
-	}
-	return v, nil
+	return readSet(c.SerialnumbersSetFile())
 }
 
-// List returns a string slice of all ISSN.
+// List returns a string slice of all ISSN, merged from all sources.
 func (c *Cacher) List() ([]string, error) {
 	if _, err := os.Stat(c.SerialnumbersFile()); err == nil {
-		f, err := os.Open(c.SerialnumbersFile())
+		b, err := ioutil.ReadFile(c.SerialnumbersFile())
 		if err != nil {
 			return nil, err
 		}
-		defer f.Close()
-		b, err := ioutil.ReadFile(c.SerialnumbersFile())
 		return strings.Split(string(b), "\n"), nil
 	}
-	if err := c.fetchSitemaps(); err != nil {
-		return nil, err
-	}
-	files, err := ioutil.ReadDir(c.SitemapDir())
-	if err != nil {
+	if err := ensureDir(c.SitemapDir()); err != nil {
 		return nil, err
 	}
-
-	// Input buffer, filenames, one per line.
-	var buf bytes.Buffer
-	for _, fi := range files {
-		if fi.Name() == "sitemap.xml" {
-			continue
-		}
-		filename := filepath.Join(c.SitemapDir(), fi.Name())
-		io.WriteString(&buf, filename+"\n")
+	if len(c.Sources) == 0 {
+		return nil, fmt.Errorf("no sources configured")
 	}
-
-	// Write one issn per line into output buffer.
-	var output bytes.Buffer
-
-	processor := parallel.NewProcessor(&buf, &output, func(b []byte) ([]byte, error) {
-		filename := strings.TrimSpace(string(b))
-		f, err := os.Open(filename)
+	merged := bitset.New()
+	for _, src := range c.Sources {
+		set, err := src.Set()
 		if err != nil {
-			return nil, err
-		}
-		defer f.Close()
-		dec := xml.NewDecoder(f)
-		var us Urlset
-		if err := dec.Decode(&us); err != nil {
-			return nil, err
-		}
-		var buf bytes.Buffer
-		for _, u := range us.URL {
-			parts := strings.Split(u.Loc, "/")
-			issn := parts[len(parts)-1]
-			io.WriteString(&buf, issn+"\n")
-		}
-		return buf.Bytes(), nil
-	})
-
-	// Give each worker two files at a time.
-	processor.BatchSize = 2
-	if err := processor.Run(); err != nil {
-		return nil, err
-	}
-
-	var result []string
-	for _, v := range strings.Split(output.String(), "\n") {
-		v = strings.TrimSpace(v)
-		if v == "" {
-			continue
+			return nil, fmt.Errorf("%s: %w", src, err)
 		}
-		result = append(result, v)
+		log.Printf("%s: %d issn", src, set.Len())
+		merged = merged.Union(set)
 	}
-	sort.Strings(result)
+	result := merged.Strings()
 	if err := atomic.WriteFile(c.SerialnumbersFile(), []byte(strings.Join(result, "\n")), 0644); err != nil {
 		return nil, err
 	}
@@ -483,17 +477,17 @@ func ensureDir(name string) error {
 	if _, err := os.Stat(name); os.IsNotExist(err) {
 		if err := os.MkdirAll(name, 0755); err != nil {
 			return err
-			log.Fatal(err)
-		} else {
-			log.Printf("created directory at: %s", name)
 		}
+		log.Printf("created directory at: %s", name)
 	}
 	return nil
 }
 
 // fetch can be plugged into miku/parallel for parallel processing. TODO(miku):
 // Make parallel a bit simpler to use outside the reader/writer realm. The byte
-// slices contains a list of issn, separated by newline.
+// slices contains a list of links, separated by newline. Links, that cannot be
+// fetched after a number of retries are recorded in the failure log and do not
+// stop the harvest.
 func fetch(b []byte) ([]byte, error) {
 	var buf bytes.Buffer
 	enc := json.NewEncoder(&buf)
@@ -503,49 +497,132 @@ func fetch(b []byte) ([]byte, error) {
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		var (
-			retryCount = 10
-			errors     []string
-		)
-		for {
-			if retryCount == 0 {
-				return nil, fmt.Errorf("giving up on %s, errors were: %v", line, errors)
+		m, val, failure := fetchLink(client, line)
+		if failure != nil {
+			log.Warnf("giving up on %s after %d attempts: %s", line, failure.Attempts, failure.Error)
+			if failures == nil {
+				continue
 			}
-			retryCount--
-
-			req, err := http.NewRequest("GET", line, nil)
-			if err != nil {
+			if err := failures.Add(failure); err != nil {
 				return nil, err
 			}
-			req.Header.Add("User-Agent", *userAgent)
-			resp, err := client.Do(req)
-			if err != nil {
+			continue
+		}
+		if m != nil {
+			n := buf.Len()
+			if err := enc.Encode(m); err != nil {
 				return nil, err
 			}
-			defer resp.Body.Close()
-			if resp.StatusCode >= 400 {
-				msg := fmt.Sprintf("got %s on %s", resp.Status, line)
-				errors = append(errors, msg)
-				log.Warn(msg)
-				continue
-			}
-			var body bytes.Buffer
-			tee := io.TeeReader(resp.Body, &body)
-			// Just a container to hold the data to serialize (compact) again.
-			var m = make(map[string]interface{})
-			if err := json.NewDecoder(tee).Decode(&m); err != nil {
-				log.Printf("%v at %s", err, line)
-				log.Println(body.String())
-				msg := fmt.Sprintf("%s failed with %s [%d]", line, err, retryCount)
-				errors = append(errors, msg)
-				log.Warn(msg)
-				continue
-			}
-			if err := enc.Encode(m); err != nil {
+			val.Hash = probecache.BodyHash(bytes.TrimSpace(buf.Bytes()[n:]))
+		}
+		// Not modified records are carried over after the harvest, their
+		// validator is renewed here.
+		if validators != nil {
+			if err := validators.Add(val); err != nil {
 				return nil, err
 			}
-			break
 		}
 	}
 	return buf.Bytes(), nil
 }
+
+// fetchLink fetches a single JSON document, with retries, and returns it
+// with the validators of the response. If all attempts fail, the returned
+// failure describes the last one. During a refresh, the request is
+// conditional; if the record has not been modified, it is recorded with the
+// refresh source and only the renewed validator is returned.
+func fetchLink(client *pester.Client, link string) (map[string]interface{}, *harvest.Validator, *harvest.Failure) {
+	failure := &harvest.Failure{URL: link, ISSN: linkISSN(link)}
+	for failure.Attempts < maxAttempts {
+		failure.Attempts++
+		failure.Time = time.Now()
+		req, err := http.NewRequest("GET", link, nil)
+		if err != nil {
+			failure.Error = err.Error()
+			return nil, nil, failure
+		}
+		req.Header.Add("User-Agent", *userAgent)
+		refresh.Conditional(req.Header, failure.ISSN)
+		resp, err := client.Do(req)
+		if err != nil {
+			failure.Error = err.Error()
+			continue
+		}
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		failure.Status, failure.Body = resp.StatusCode, snippet(b)
+		if err != nil {
+			failure.Error = err.Error()
+			continue
+		}
+		val := &harvest.Validator{
+			ISSN:         failure.ISSN,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Checked:      failure.Time,
+		}
+		if resp.StatusCode == http.StatusNotModified {
+			refresh.NotModified(failure.ISSN)
+			// A 304 may omit validators, that did not change.
+			if prev := refresh.Validator(failure.ISSN); prev != nil {
+				val.Hash = prev.Hash
+				if val.ETag == "" {
+					val.ETag = prev.ETag
+				}
+				if val.LastModified == "" {
+					val.LastModified = prev.LastModified
+				}
+			}
+			return nil, val, nil
+		}
+		if resp.StatusCode >= 400 {
+			failure.Error = fmt.Sprintf("got %s on %s", resp.Status, link)
+			log.Warn(failure.Error)
+			continue
+		}
+		// Sometimes, a supposedly JSON response comes back as XML, convert
+		// it into JSON-LD; stub and error pages are not worth retrying.
+		body, kind, err := sniff.Normalize(b)
+		responses.Add(kind)
+		switch {
+		case err != nil:
+			failure.Error = fmt.Sprintf("cannot convert %s response from %s: %v", kind, link, err)
+			log.Warn(failure.Error)
+			continue
+		case kind == sniff.HTMLStub:
+			failure.Error = fmt.Sprintf("no data available for %s", link)
+			return nil, nil, failure
+		case kind != sniff.JSONLD && kind != sniff.RDFXML:
+			failure.Error = fmt.Sprintf("unexpected %s response from %s", kind, link)
+			log.Warn(failure.Error)
+			continue
+		}
+		// Just a container to hold the data to serialize (compact) again.
+		var m = make(map[string]interface{})
+		if err := json.Unmarshal(body, &m); err != nil {
+			failure.Error = fmt.Sprintf("%s failed with %s [%d]", link, err, failure.Attempts)
+			log.Warn(failure.Error)
+			continue
+		}
+		return m, val, nil
+	}
+	return nil, nil, failure
+}
+
+// linkISSN returns the ISSN from a portal link, e.g.
+// https://portal.issn.org/resource/ISSN/1521-9615?format=json
+func linkISSN(link string) string {
+	if i := strings.Index(link, "?"); i >= 0 {
+		link = link[:i]
+	}
+	return path.Base(link)
+}
+
+// snippet returns the start of a response body for the failure log.
+func snippet(b []byte) string {
+	const maxLen = 512
+	if len(b) > maxLen {
+		b = b[:maxLen]
+	}
+	return string(b)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCacherSources(t *testing.T) {
+	dir := t.TempDir()
+	defer func(d string, s []string) { *cacheDir, sources = d, s }(*cacheDir, sources)
+	*cacheDir = filepath.Join(dir, "cache")
+	for _, c := range []struct {
+		name string
+		list string
+	}{
+		{"a.tsv", "0000-0019\n1932-6203\n"},
+		{"b.tsv", "0378-5955\n"},
+	} {
+		filename := filepath.Join(dir, c.name)
+		if err := os.WriteFile(filename, []byte(c.list), 0644); err != nil {
+			t.Fatal(err)
+		}
+		sources = []string{"snapshot:" + filename}
+		cacher, err := NewCacher()
+		if err != nil {
+			t.Fatal(err)
+		}
+		list, err := cacher.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := strings.Join(list, "\n")+"\n", c.list; got != want {
+			t.Errorf("sources %v: got %q, want %q", sources, got, want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/miku/issnlister/atomic"
+	"github.com/miku/issnlister/probecache"
+	"github.com/miku/issnlister/record"
+	log "github.com/sirupsen/logrus"
+)
+
+// Mapping collects ISSN to ISSN-L links and notes inconsistencies.
+type Mapping struct {
+	Links    map[string]string // ISSN to ISSN-L, may be empty.
+	Problems []MappingProblem
+}
+
+// MappingProblem describes a record, that does not fit into a consistent
+// ISSN-L grouping.
+type MappingProblem struct {
+	Kind   string // missing-issnl, conflict, not-self-linked or dangling
+	ISSN   string
+	ISSNL  string
+	Detail string
+}
+
+// NewMapping returns an empty mapping.
+func NewMapping() *Mapping {
+	return &Mapping{Links: make(map[string]string)}
+}
+
+// Add adds the link from a single record. If an ISSN is seen again, a
+// missing ISSN-L is filled in; two different ISSN-L are reported as a
+// conflict and the later one is kept.
+func (m *Mapping) Add(r *record.Record) {
+	prev, ok := m.Links[r.ISSN]
+	switch {
+	case !ok || prev == "":
+		m.Links[r.ISSN] = r.ISSNL
+	case r.ISSNL != "" && r.ISSNL != prev:
+		m.Problems = append(m.Problems, MappingProblem{
+			Kind:   "conflict",
+			ISSN:   r.ISSN,
+			ISSNL:  r.ISSNL,
+			Detail: fmt.Sprintf("also linked to %q", prev),
+		})
+		m.Links[r.ISSN] = r.ISSNL
+	}
+}
+
+// Check looks for records without ISSN-L and for ISSN-L, that group
+// inconsistently: the linking ISSN of a group should be part of the group
+// itself.
+func (m *Mapping) Check() {
+	for _, v := range m.keys() {
+		l := m.Links[v]
+		if l == "" {
+			m.Problems = append(m.Problems, MappingProblem{Kind: "missing-issnl", ISSN: v})
+			continue
+		}
+		ll, ok := m.Links[l]
+		switch {
+		case !ok:
+			m.Problems = append(m.Problems, MappingProblem{
+				Kind:   "dangling",
+				ISSN:   v,
+				ISSNL:  l,
+				Detail: "no record for issn-l",
+			})
+		case ll != l:
+			m.Problems = append(m.Problems, MappingProblem{
+				Kind:   "not-self-linked",
+				ISSN:   v,
+				ISSNL:  l,
+				Detail: fmt.Sprintf("issn-l %s is linked to %q", l, ll),
+			})
+		}
+	}
+}
+
+// keys returns the sorted ISSN.
+func (m *Mapping) keys() []string {
+	keys := make([]string, 0, len(m.Links))
+	for k := range m.Links {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteTo writes the ISSN to ISSN-L mapping as TSV, sorted by ISSN. Records
+// without ISSN-L are included with an empty second column.
+func (m *Mapping) WriteTo(w io.Writer) (int64, error) {
+	var (
+		bw = bufio.NewWriter(w)
+		n  int64
+	)
+	for _, k := range m.keys() {
+		c, err := fmt.Fprintf(bw, "%s\t%s\n", k, m.Links[k])
+		n += int64(c)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+// WriteReverse writes the ISSN-L to ISSN mapping as TSV, sorted by ISSN-L.
+func (m *Mapping) WriteReverse(w io.Writer) error {
+	type pair struct{ l, v string }
+	pairs := make([]pair, 0, len(m.Links))
+	for v, l := range m.Links {
+		if l != "" {
+			pairs = append(pairs, pair{l, v})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].l != pairs[j].l {
+			return pairs[i].l < pairs[j].l
+		}
+		return pairs[i].v < pairs[j].v
+	})
+	bw := bufio.NewWriter(w)
+	for _, p := range pairs {
+		if _, err := fmt.Fprintf(bw, "%s\t%s\n", p.l, p.v); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// addHarvest adds all records from a harvest file.
+func (m *Mapping) addHarvest(filename string) error {
+	return eachHarvestRecord(filename, func(r *record.Record) error {
+		m.Add(r)
+		return nil
+	})
+}
+
+// addProbeCache adds the saved bodies of all registered ISSN in an issnprobe
+// cache directory or database.
+func (m *Mapping) addProbeCache(path string) error {
+	c, err := probecache.OpenReadOnly(path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return c.Walk(func(pr *probecache.Result) error {
+		if !pr.Registered {
+			return nil
+		}
+		b, err := c.Body(pr.ISSN)
+		if errors.Is(err, probecache.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		r, err := record.DecodeISSN(b, pr.ISSN)
+		if err != nil {
+			log.Warnf("%s: %v", pr.ISSN, err)
+			return nil
+		}
+		m.Add(r)
+		return nil
+	})
+}
+
+// writeFile atomically writes the output of f to a file.
+func writeFile(filename string, f func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := f(&buf); err != nil {
+		return err
+	}
+	return atomic.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// runMapping writes ISSN to ISSN-L and ISSN-L to ISSN mappings from harvest
+// files or an issnprobe cache, replacing make_issn_issnl_mapping.py.
+//
+//	$ issnlister mapping data.ndjson
+func runMapping(args []string) error {
+	fs := flag.NewFlagSet("mapping", flag.ExitOnError)
+	var (
+		prefix     = fs.String("p", time.Now().Format("20060102"), "output filename prefix")
+		probeCache = fs.String("probecache", "", "read saved bodies from an issnprobe cache directory or database (.db)")
+		reportFile = fs.String("r", "", "write problems as TSV (kind, issn, issnl, detail) to this file")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s mapping [-p PREFIX] [-probecache DIR] [-r FILE] [HARVEST ...]\n\n", appName)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 && *probeCache == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	m := NewMapping()
+	for _, filename := range fs.Args() {
+		if err := m.addHarvest(filename); err != nil {
+			return err
+		}
+	}
+	if *probeCache != "" {
+		if err := m.addProbeCache(*probeCache); err != nil {
+			return err
+		}
+	}
+	m.Check()
+	var (
+		forward = *prefix + ".ISSN-to-ISSN-L.txt"
+		reverse = *prefix + ".ISSNL-to-ISSN.txt"
+	)
+	if err := writeFile(forward, func(w io.Writer) error {
+		_, err := m.WriteTo(w)
+		return err
+	}); err != nil {
+		return err
+	}
+	if err := writeFile(reverse, m.WriteReverse); err != nil {
+		return err
+	}
+	counts := make(map[string]int)
+	for _, p := range m.Problems {
+		counts[p.Kind]++
+	}
+	log.Printf("wrote %d links to %s and %s, problems: %v", len(m.Links), forward, reverse, counts)
+	if *reportFile == "" {
+		return nil
+	}
+	return writeFile(*reportFile, func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		for _, p := range m.Problems {
+			fmt.Fprintf(bw, "%s\t%s\t%s\t%s\n", p.Kind, p.ISSN, p.ISSNL, p.Detail)
+		}
+		return bw.Flush()
+	})
+}
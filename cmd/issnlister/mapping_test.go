@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/miku/issnlister/record"
+)
+
+func TestMappingAdd(t *testing.T) {
+	m := NewMapping()
+	m.Add(&record.Record{ISSN: "0000-0019"})
+	m.Add(&record.Record{ISSN: "0000-0019", ISSNL: "0000-0019"})
+	m.Add(&record.Record{ISSN: "0000-0019"})
+	m.Add(&record.Record{ISSN: "1932-6203", ISSNL: "1932-6203"})
+	m.Add(&record.Record{ISSN: "1932-6203", ISSNL: "1932-6203"})
+	if len(m.Problems) != 0 {
+		t.Fatalf("got problems %v, want none", m.Problems)
+	}
+	if got := m.Links["0000-0019"]; got != "0000-0019" {
+		t.Errorf("got %q, want 0000-0019", got)
+	}
+	m.Add(&record.Record{ISSN: "1932-6203", ISSNL: "0000-0019"})
+	if len(m.Problems) != 1 || m.Problems[0].Kind != "conflict" {
+		t.Errorf("got problems %v, want one conflict", m.Problems)
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/harvest"
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/record"
+	"github.com/miku/issnlister/store"
+	log "github.com/sirupsen/logrus"
+)
+
+// refreshSource is an earlier harvest file or record store, from which
+// recently fetched records are carried over in an incremental refresh, so
+// only older records and new ISSN need to be fetched again.
+//
+// The fetch time of a record in a store is the time of its latest version.
+// For a record in a harvest file, it is the time of the last check in the
+// validator log, else the modification date of the record, which cannot be
+// later than the fetch, and only else the modification time of the file.
+//
+// Older records are requested conditionally, with the ETag and Last-Modified
+// the server sent for the last fetch, kept in a validator log beside the
+// source (see harvest.ValidatorsFile). Without a validator, a store falls
+// back to If-Modified-Since with the fetch time. Records not modified are
+// carried over after the harvest.
+type refreshSource struct {
+	Filename   string
+	All        *bitset.Set // All ISSN in the source.
+	Fresh      *bitset.Set // ISSN fetched within the maximum age.
+	isStore    bool
+	modTime    time.Time                     // Modification time of a harvest file.
+	since      map[issn.ISSN]int64           // Fetch time of stale store records, unix seconds.
+	validators map[string]*harvest.Validator // Last response validators per ISSN.
+	mu         sync.Mutex                    // Protects unchanged.
+	unchanged  *bitset.Set                   // ISSN answered with 304 Not Modified.
+}
+
+// readRefreshSource finds all records in a harvest file or store and those
+// fetched less than maxAge ago.
+func readRefreshSource(filename string, maxAge time.Duration) (*refreshSource, error) {
+	src := &refreshSource{
+		Filename:  filename,
+		All:       bitset.New(),
+		Fresh:     bitset.New(),
+		since:     make(map[issn.ISSN]int64),
+		unchanged: bitset.New(),
+	}
+	cutoff := time.Now().Add(-maxAge)
+	vs, err := harvest.ReadValidators(harvest.ValidatorsFile(filename), nil)
+	if err != nil {
+		return nil, err
+	}
+	src.validators = vs
+	s, err := store.OpenReadOnly(filename)
+	switch {
+	case errors.Is(err, store.ErrFormat):
+		fi, err := os.Stat(filename)
+		if err != nil {
+			return nil, err
+		}
+		src.modTime = fi.ModTime()
+		err = eachHarvestLine(filename, func(v issn.ISSN, r *record.Record, _ []byte) error {
+			src.All.Add(v)
+			if src.fetched(v, r).After(cutoff) {
+				src.Fresh.Add(v)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		defer s.Close()
+		src.isStore = true
+		for _, v := range s.Keys() {
+			vs := s.Versions(v)
+			if len(vs) == 0 {
+				continue
+			}
+			src.All.Add(v)
+			if t := vs[len(vs)-1].Time; t.After(cutoff) {
+				src.Fresh.Add(v)
+			} else {
+				src.since[v] = t.Unix()
+			}
+		}
+	}
+	for k := range src.validators {
+		if !src.All.ContainsString(k) {
+			delete(src.validators, k)
+		}
+	}
+	return src, nil
+}
+
+// fetched returns the fetch time of a record in a harvest file.
+func (src *refreshSource) fetched(v issn.ISSN, r *record.Record) time.Time {
+	if val := src.validators[v.String()]; val != nil && !val.Checked.IsZero() {
+		return val.Checked
+	}
+	if t, err := r.ModifiedTime(); err == nil && !t.IsZero() && t.Before(src.modTime) {
+		return t
+	}
+	return src.modTime
+}
+
+// Validator returns the validator of the last fetch of an ISSN, or nil. It
+// is safe to call on a nil source.
+func (src *refreshSource) Validator(v string) *harvest.Validator {
+	if src == nil {
+		return nil
+	}
+	return src.validators[v]
+}
+
+// Conditional sets the headers for a conditional request for a stale ISSN:
+// If-None-Match and If-Modified-Since from the validator of the last fetch
+// or, for a store record without validator, If-Modified-Since with its fetch
+// time. Fresh records are not fetched at all. It is safe to call on a nil
+// source.
+func (src *refreshSource) Conditional(h http.Header, v string) {
+	if src == nil {
+		return
+	}
+	w, err := issn.Parse(v)
+	if err != nil || src.Fresh.Contains(w) {
+		return
+	}
+	if val := src.validators[v]; val != nil && (val.ETag != "" || val.LastModified != "") {
+		if val.ETag != "" {
+			h.Set("If-None-Match", val.ETag)
+		}
+		if val.LastModified != "" {
+			h.Set("If-Modified-Since", val.LastModified)
+		}
+		return
+	}
+	if t, ok := src.since[w]; ok {
+		h.Set("If-Modified-Since", time.Unix(t, 0).UTC().Format(http.TimeFormat))
+	}
+}
+
+// NotModified records an ISSN, that has not been modified since the last
+// fetch. It is safe for concurrent use.
+func (src *refreshSource) NotModified(v string) {
+	w, err := issn.Parse(v)
+	if src == nil || err != nil {
+		return
+	}
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	src.unchanged.Add(w)
+}
+
+// CarryOverUnchanged copies the records, that have not been modified, as
+// checked now.
+func (src *refreshSource) CarryOverUnchanged(w io.Writer, dst *store.Store) (int, error) {
+	src.mu.Lock()
+	keep := src.unchanged.Clone()
+	src.mu.Unlock()
+	if keep.Len() == 0 {
+		return 0, nil
+	}
+	return src.CarryOver(w, dst, keep, time.Now())
+}
+
+// CarryOver copies the records in keep from the source to a harvest output
+// or, if dst is not nil, to a store, with their fetch time or t, if t is
+// not zero. Copied ISSN are removed from keep, so a record is copied at
+// most once. If t is zero, the validators of the copied records are copied
+// to the validator log, if one is open, or an entry with just the fetch
+// time; records checked at t got a new validator during the harvest.
+func (src *refreshSource) CarryOver(w io.Writer, dst *store.Store, keep *bitset.Set, t time.Time) (n int, err error) {
+	var (
+		buf bytes.Buffer
+		bw  = bufio.NewWriter(w)
+	)
+	defer func() {
+		if ferr := bw.Flush(); err == nil {
+			err = ferr
+		}
+	}()
+	put := func(v issn.ISSN, fetched time.Time, body []byte) error {
+		keep.Remove(v)
+		n++
+		if !t.IsZero() {
+			fetched = t
+		} else if validators != nil {
+			// Keep the fetch time with the record, also without a
+			// validator, instead of the time of the new file.
+			val := src.validators[v.String()]
+			if val == nil {
+				val = &harvest.Validator{ISSN: v.String(), Checked: fetched}
+			}
+			if err := validators.Add(val); err != nil {
+				return err
+			}
+		}
+		if dst != nil {
+			return dst.Put(v, fetched, body)
+		}
+		buf.Reset()
+		if err := json.Compact(&buf, body); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		_, err := bw.Write(buf.Bytes())
+		return err
+	}
+	if !src.isStore {
+		err = eachHarvestLine(src.Filename, func(v issn.ISSN, r *record.Record, line []byte) error {
+			if !keep.Contains(v) {
+				return nil
+			}
+			return put(v, src.fetched(v, r), line)
+		})
+		return n, err
+	}
+	s, err := store.OpenReadOnly(src.Filename)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	for _, v := range keep.Values() {
+		ver, body, err := s.Get(v)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		if err := put(v, ver.Time, body); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// CarryOverFailed copies the records, that are in the source, but were
+// recorded in the failure log since a given time.
+func (src *refreshSource) CarryOverFailed(w io.Writer, dst *store.Store, failureFile string, since time.Time) (int, error) {
+	failed, err := harvest.ReadFailures(failureFile)
+	if err != nil {
+		return 0, err
+	}
+	keep := bitset.New()
+	for _, f := range failed {
+		if f.Time.Before(since) {
+			continue
+		}
+		if v, err := issn.Parse(f.ISSN); err == nil && src.All.Contains(v) {
+			keep.Add(v)
+		}
+	}
+	if keep.Len() == 0 {
+		return 0, nil
+	}
+	return src.CarryOver(w, dst, keep, time.Time{})
+}
+
+// sameFile returns true, if both names refer to the same existing file.
+func sameFile(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fa, fb)
+}
+
+// eachHarvestLine calls f with the ISSN, the decoded record and the line of
+// every record in a harvest file. Lines without a valid ISSN are skipped, a
+// partial last line is ignored.
+func eachHarvestLine(filename string, f func(v issn.ISSN, r *record.Record, line []byte) error) error {
+	rc, err := harvest.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	br := bufio.NewReader(rc)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		r, err := record.Decode(line)
+		if err != nil {
+			log.Warnf("%s: %v", filename, err)
+			continue
+		}
+		v, err := issn.Parse(r.ISSN)
+		if err != nil {
+			continue
+		}
+		if err := f(v, r, bytes.TrimRight(line, "\n")); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/harvest"
+	"github.com/miku/issnlister/issn"
+)
+
+func TestFetchConditional(t *testing.T) {
+	const (
+		etag         = `"v1"`
+		lastModified = "Mon, 02 Mar 2026 10:00:00 GMT"
+	)
+	var (
+		mu      sync.Mutex
+		headers = make(map[string]http.Header)
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := strings.TrimPrefix(r.URL.Path, "/resource/ISSN/")
+		mu.Lock()
+		headers[v] = r.Header.Clone()
+		mu.Unlock()
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write([]byte(`{"@context": {}, "@graph": [{"@id": "resource/ISSN/` + v + `"}]}`))
+	}))
+	defer ts.Close()
+
+	// 1932-6203 was fetched before and is stale, 0000-0019 is new.
+	src := &refreshSource{
+		All:       bitset.New(),
+		Fresh:     bitset.New(),
+		since:     make(map[issn.ISSN]int64),
+		unchanged: bitset.New(),
+		validators: map[string]*harvest.Validator{
+			"1932-6203": {ISSN: "1932-6203", ETag: etag, LastModified: lastModified, Hash: "abc"},
+		},
+	}
+	src.All.Add(issn.MustParse("1932-6203"))
+	refresh = src
+	defer func() { refresh = nil }()
+
+	filename := filepath.Join(t.TempDir(), "data.ndj.validators")
+	var err error
+	if validators, err = harvest.OpenValidatorLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { validators = nil }()
+	started := time.Now()
+	b, err := fetch([]byte(ts.URL + "/resource/ISSN/1932-6203?format=json\n" +
+		ts.URL + "/resource/ISSN/0000-0019?format=json\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validators.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(string(b), "\n"); n != 1 {
+		t.Fatalf("got %d records, want 1: %s", n, b)
+	}
+	if !strings.Contains(string(b), "0000-0019") {
+		t.Errorf("new record missing: %s", b)
+	}
+	if !src.unchanged.Contains(issn.MustParse("1932-6203")) {
+		t.Errorf("1932-6203 not recorded as unchanged")
+	}
+	h := headers["1932-6203"]
+	if got := h.Get("If-None-Match"); got != etag {
+		t.Errorf("If-None-Match: got %q, want %q", got, etag)
+	}
+	if got := h.Get("If-Modified-Since"); got != lastModified {
+		t.Errorf("If-Modified-Since: got %q, want %q", got, lastModified)
+	}
+	h = headers["0000-0019"]
+	if h.Get("If-None-Match") != "" || h.Get("If-Modified-Since") != "" {
+		t.Errorf("unconditional request expected for a new ISSN, got %v", h)
+	}
+
+	vs, err := harvest.ReadValidators(filename, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 2 {
+		t.Fatalf("got %d validators, want 2", len(vs))
+	}
+	for _, v := range vs {
+		if v.ETag != etag || v.LastModified != lastModified {
+			t.Errorf("%s: got %q %q, want %q %q", v.ISSN, v.ETag, v.LastModified, etag, lastModified)
+		}
+		if v.Checked.Before(started.Add(-time.Second)) {
+			t.Errorf("%s: checked at %v, before the harvest", v.ISSN, v.Checked)
+		}
+	}
+	if got := vs["1932-6203"].Hash; got != "abc" {
+		t.Errorf("hash of not modified record: got %q, want abc", got)
+	}
+	if got := vs["0000-0019"].Hash; len(got) != 32 {
+		t.Errorf("hash of new record: got %q", got)
+	}
+}
+
+func TestReadRefreshSourceHarvest(t *testing.T) {
+	var (
+		dir      = t.TempDir()
+		filename = filepath.Join(dir, "data.ndj")
+		now      = time.Now()
+		doc      = func(v, modified string) string {
+			s := `{"@graph": [{"@id": "resource/ISSN/` + v + `", "mainTitle": "T"}`
+			if modified != "" {
+				s += `, {"@id": "resource/ISSN/` + v + `#Record", "mainEntity": "resource/ISSN/` + v + `", "modified": "` + modified + `"}`
+			}
+			return s + "]}\n"
+		}
+	)
+	// The file is new, as after an earlier refresh, but only records
+	// checked or modified recently are fresh.
+	data := doc("0000-0019", "") + doc("1932-6203", "") + doc("0378-5955", "20160218133127.0") +
+		doc("2434-561X", now.Add(-24*time.Hour).UTC().Format("20060102150405.0")) + doc("2257-6754", "")
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	vl, err := harvest.OpenValidatorLog(harvest.ValidatorsFile(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for v, age := range map[string]time.Duration{"0000-0019": 60 * 24 * time.Hour, "1932-6203": time.Hour} {
+		if err := vl.Add(&harvest.Validator{ISSN: v, Checked: now.Add(-age)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := vl.Close(); err != nil {
+		t.Fatal(err)
+	}
+	src, err := readRefreshSource(filename, 30*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := src.All.Len(); n != 5 {
+		t.Errorf("got %d records, want 5", n)
+	}
+	for v, want := range map[string]bool{
+		"0000-0019": false, // Checked 60 days ago.
+		"1932-6203": true,  // Checked an hour ago.
+		"0378-5955": false, // Modified in 2016, fetched at an unknown time.
+		"2434-561X": true,  // Modified yesterday.
+		"2257-6754": true,  // Only the file time.
+	} {
+		if got := src.Fresh.ContainsString(v); got != want {
+			t.Errorf("%s: fresh %v, want %v", v, got, want)
+		}
+	}
+
+	// Carried over records keep their fetch time in the new sidecar.
+	output := filepath.Join(dir, "next.ndj")
+	if validators, err = harvest.OpenValidatorLog(harvest.ValidatorsFile(output)); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { validators = nil }()
+	var buf strings.Builder
+	if n, err := src.CarryOver(&buf, nil, src.Fresh.Clone(), time.Time{}); err != nil || n != 3 {
+		t.Fatalf("carry over: got %d, %v, want 3", n, err)
+	}
+	if err := validators.Close(); err != nil {
+		t.Fatal(err)
+	}
+	vs, err := harvest.ReadValidators(harvest.ValidatorsFile(output), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 3 {
+		t.Fatalf("got %d validators, want 3", len(vs))
+	}
+	if got := vs["2257-6754"].Checked; !got.Equal(src.modTime) {
+		t.Errorf("fetch time of record without validator: got %v, want %v", got, src.modTime)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/miku/issnlister/harvest"
+	"github.com/miku/issnlister/stringutil"
+	"github.com/miku/parallel"
+	log "github.com/sirupsen/logrus"
+)
+
+// runRetryFailed re-attempts the downloads recorded in a failure log.
+// Successful records are appended to a harvest file (or written to stdout),
+// the failure log is replaced by the entries, that failed again. As after a
+// harvest, the validator log is compacted.
+//
+//	$ issnlister retry-failed -F file.ndj.failed -o file.ndj
+func runRetryFailed(args []string) error {
+	fs := flag.NewFlagSet("retry-failed", flag.ExitOnError)
+	var (
+		failed = fs.String("F", "", "failure log to retry (required)")
+		output = fs.String("o", "", "harvest file to append to (default: stdout)")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s retry-failed -F FILE [-o HARVEST]\n\n", appName)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *failed == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	entries, err := harvest.ReadFailures(*failed)
+	if err != nil {
+		return err
+	}
+	var (
+		w  io.Writer = os.Stdout
+		hw *harvest.Writer
+	)
+	if *output != "" {
+		done, err := harvest.Resume(*output)
+		if err != nil {
+			return err
+		}
+		if hw, err = harvest.NewWriter(*output); err != nil {
+			return err
+		}
+		w = hw
+		if validators, err = harvest.OpenValidatorLog(harvest.ValidatorsFile(*output)); err != nil {
+			return err
+		}
+		var pending []*harvest.Failure
+		for _, f := range entries {
+			if !done.ContainsString(f.ISSN) {
+				pending = append(pending, f)
+			}
+		}
+		entries = pending
+	}
+	links := make([]string, len(entries))
+	for i, f := range entries {
+		links[i] = f.URL
+	}
+	log.Printf("retrying %d failed links from %s", len(links), *failed)
+	// A stale .retry file from an interrupted run is overwritten.
+	tmp := *failed + ".retry"
+	if failures, err = harvest.CreateFailureLog(tmp); err != nil {
+		return err
+	}
+	proc := parallel.NewProcessor(stringutil.SliceReader(links), w, fetch)
+	proc.BatchSize = *batchSize
+	proc.NumWorkers = *numWorkers
+	if err := proc.Run(); err != nil {
+		if hw != nil {
+			hw.Close()
+		}
+		return err
+	}
+	if err := failures.Close(); err != nil {
+		return err
+	}
+	// The failure log is only replaced, if the harvest has been written.
+	if hw != nil {
+		if err := hw.Close(); err != nil {
+			return err
+		}
+		if err := validators.Close(); err != nil {
+			return err
+		}
+		if err := harvest.CompactValidators(harvest.ValidatorsFile(*output)); err != nil {
+			return err
+		}
+	}
+	if n := responses.Anomalies(); n > 0 {
+		log.Printf("%d anomalous responses (%s)", n, &responses)
+	}
+	log.Printf("%d of %d links failed again", failures.Len(), len(links))
+	if failures.Len() == 0 {
+		os.Remove(tmp)
+		return os.Remove(*failed)
+	}
+	return os.Rename(tmp, *failed)
+}
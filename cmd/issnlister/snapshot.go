@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/miku/issnlister/bitset"
+	log "github.com/sirupsen/logrus"
+)
+
+// readSet reads a set of ISSN from a list or a serialized bitset, warning
+// about invalid values in a list.
+func readSet(filename string) (*bitset.Set, error) {
+	s, err := bitset.ReadFile(filename)
+	if bitset.IsInvalid(err) {
+		log.Warnf("%s: %v", filename, err)
+		return s, nil
+	}
+	return s, err
+}
+
+// snapshotFile resolves a snapshot name to a filename. The name is either a
+// date (2006-01-02), referring to a day directory in the cache, or a path to
+// a list or serialized bitset.
+func snapshotFile(name string) (string, error) {
+	if _, err := time.Parse("2006-01-02", name); err != nil {
+		return name, nil
+	}
+	c := &Cacher{Directory: *cacheDir, Prefix: name}
+	for _, filename := range []string{c.SerialnumbersSetFile(), c.SerialnumbersFile()} {
+		if _, err := os.Stat(filename); err == nil {
+			return filename, nil
+		}
+	}
+	return "", fmt.Errorf("no snapshot for %s in %s", name, filepath.Join(c.Directory, c.Prefix))
+}
+
+// loadSnapshot reads the set of ISSN of a snapshot, given as a date or a
+// filename.
+func loadSnapshot(name string) (*bitset.Set, error) {
+	filename, err := snapshotFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return readSet(filename)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miku/issnlister/atomic"
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/probecache"
+	"github.com/miku/issnlister/record"
+	"github.com/miku/parallel"
+	"github.com/sethgrid/pester"
+	log "github.com/sirupsen/logrus"
+)
+
+// Source yields a set of ISSN from some place we can still get them from.
+// Values, which are not valid ISSN are dropped.
+type Source interface {
+	fmt.Stringer
+	Set() (*bitset.Set, error)
+}
+
+// ParseSource parses a source specification of the form kind:location. The
+// sitemap source caches downloads in dir.
+func ParseSource(spec, dir string) (Source, error) {
+	kind, loc, ok := strings.Cut(spec, ":")
+	if !ok || loc == "" {
+		return nil, fmt.Errorf("invalid source %q, want kind:location", spec)
+	}
+	switch kind {
+	case "sitemap":
+		return &SitemapSource{URL: loc, Dir: dir}, nil
+	case "snapshot":
+		return &SnapshotSource{Filename: loc}, nil
+	case "harvest":
+		return &HarvestSource{Filename: loc}, nil
+	case "probecache":
+		return &ProbeCacheSource{Dir: loc}, nil
+	default:
+		return nil, fmt.Errorf("unknown source kind: %s", kind)
+	}
+}
+
+// SnapshotSource reads a previous list, e.g. issn.tsv, one ISSN per line. A
+// serialized bitset works as well.
+type SnapshotSource struct {
+	Filename string
+}
+
+func (s *SnapshotSource) String() string { return "snapshot:" + s.Filename }
+
+// Set returns the ISSN in the snapshot.
+func (s *SnapshotSource) Set() (*bitset.Set, error) {
+	return readSet(s.Filename)
+}
+
+// HarvestSource reads the ISSN from a previous metadata harvest, as written by
+// issnlister -m, one JSON document per line.
+type HarvestSource struct {
+	Filename string
+}
+
+func (s *HarvestSource) String() string { return "harvest:" + s.Filename }
+
+// Set returns the ISSN of all records in the harvest.
+func (s *HarvestSource) Set() (*bitset.Set, error) {
+	set := bitset.New()
+	err := eachHarvestLine(s.Filename, func(v issn.ISSN, _ *record.Record, _ []byte) error {
+		set.Add(v)
+		return nil
+	})
+	return set, err
+}
+
+// ProbeCacheSource reads the ISSN found registered by issnprobe, from a
+// cache directory or database.
+type ProbeCacheSource struct {
+	Dir string
+}
+
+func (s *ProbeCacheSource) String() string { return "probecache:" + s.Dir }
+
+// Set returns all ISSN marked as registered in the probe cache.
+func (s *ProbeCacheSource) Set() (*bitset.Set, error) {
+	c, err := probecache.OpenReadOnly(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	set := bitset.New()
+	err = c.Walk(func(r *probecache.Result) error {
+		if !r.Registered {
+			return nil
+		}
+		if v, err := issn.Parse(r.ISSN); err == nil {
+			set.Add(v)
+		}
+		return nil
+	})
+	return set, err
+}
+
+// SitemapSource is the legacy sitemap at https://portal.issn.org/sitemap.xml,
+// which has been removed in 04/2026. The sitemap contains about 40 sub
+// sitemaps, each with 50000 links. All sitemaps are cached in Dir.
+type SitemapSource struct {
+	URL  string
+	Dir  string
+	Locs []string // Sitemap locations.
+}
+
+func (s *SitemapSource) String() string { return "sitemap:" + s.URL }
+
+// SitemapFile returns the filename for the global sitemap.
+func (s *SitemapSource) SitemapFile() string {
+	return filepath.Join(s.Dir, "sitemap.xml")
+}
+
+// locFile returns the cache filename for a linked sitemap.
+func (s *SitemapSource) locFile(loc string) string {
+	// https://portal.issn.org/sitemap6.xml
+	parts := strings.Split(loc, "/")
+	return filepath.Join(s.Dir, parts[len(parts)-1])
+}
+
+// fetchSitemapIndex downloads and caches the main sitemap file.
+func (s *SitemapSource) fetchSitemapIndex() error {
+	if err := ensureDir(s.Dir); err != nil {
+		return err
+	}
+	if _, err := os.Stat(s.SitemapFile()); err == nil {
+		return nil
+	}
+	resp, err := pester.Get(s.URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("failed to fetch sitemap: %d", resp.StatusCode)
+	}
+	return atomic.WriteFileReader(s.SitemapFile(), resp.Body, 0644)
+}
+
+// fetchSitemaps tries to fetch all linked sitemaps (currently around 40).
+func (s *SitemapSource) fetchSitemaps() error {
+	if err := s.findLocations(); err != nil {
+		return err
+	}
+	for _, loc := range s.Locs {
+		filename := s.locFile(loc)
+		if _, err := os.Stat(filename); err == nil {
+			log.Printf("%s cached at %s", loc, filename)
+			continue
+		}
+		log.Println(loc)
+		if err := fetchFile(loc, filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchFile downloads a link into a file.
+func fetchFile(link, filename string) error {
+	resp, err := pester.Get(link)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("failed to fetch sitemap at %s: %d", link, resp.StatusCode)
+	}
+	return atomic.WriteFileReader(filename, resp.Body, 0644)
+}
+
+// findLocations populates the linked sitemaps.
+func (s *SitemapSource) findLocations() error {
+	if err := s.fetchSitemapIndex(); err != nil {
+		return err
+	}
+	f, err := os.Open(s.SitemapFile())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := xml.NewDecoder(f)
+	var si Sitemapindex
+	if err := dec.Decode(&si); err != nil {
+		return err
+	}
+	s.Locs = nil
+	for _, sm := range si.Sitemap {
+		s.Locs = append(s.Locs, sm.Loc)
+	}
+	return nil
+}
+
+// Set returns the ISSN linked from all sitemaps.
+func (s *SitemapSource) Set() (*bitset.Set, error) {
+	if err := s.fetchSitemaps(); err != nil {
+		return nil, err
+	}
+	// Input buffer, filenames, one per line.
+	var buf bytes.Buffer
+	for _, loc := range s.Locs {
+		io.WriteString(&buf, s.locFile(loc)+"\n")
+	}
+
+	// Write one issn per line into output buffer.
+	var output bytes.Buffer
+
+	processor := parallel.NewProcessor(&buf, &output, func(b []byte) ([]byte, error) {
+		filename := strings.TrimSpace(string(b))
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		dec := xml.NewDecoder(f)
+		var us Urlset
+		if err := dec.Decode(&us); err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		for _, u := range us.URL {
+			parts := strings.Split(u.Loc, "/")
+			io.WriteString(&buf, parts[len(parts)-1]+"\n")
+		}
+		return buf.Bytes(), nil
+	})
+
+	// Give each worker two files at a time.
+	processor.BatchSize = 2
+	if err := processor.Run(); err != nil {
+		return nil, err
+	}
+	set, err := bitset.ReadList(&output)
+	if bitset.IsInvalid(err) {
+		log.Warnf("%s: %v", s, err)
+		return set, nil
+	}
+	return set, err
+}
+
+// Sitemapindex was generated 2019-09-28 18:56:12 by tir on sol.
+type Sitemapindex struct {
+	XMLName xml.Name `xml:"sitemapindex"`
+	Text    string   `xml:",chardata"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Sitemap []struct {
+		Text    string `xml:",chardata"`
+		Loc     string `xml:"loc"`     // https://portal.issn.org/s...
+		Lastmod string `xml:"lastmod"` // 2019-09-27, 2019-09-27, 2...
+	} `xml:"sitemap"`
+}
+
+// Urlset was generated 2019-09-28 18:58:13 by tir on sol.
+type Urlset struct {
+	XMLName xml.Name `xml:"urlset"`
+	Text    string   `xml:",chardata"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Xhtml   string   `xml:"xhtml,attr"`
+	URL     []struct {
+		Text string `xml:",chardata"`
+		Loc  string `xml:"loc"` // https://portal.issn.org/r...
+		Link []struct {
+			Text     string `xml:",chardata"`
+			Rel      string `xml:"rel,attr"`
+			Hreflang string `xml:"hreflang,attr"`
+			Href     string `xml:"href,attr"`
+		} `xml:"link"`
+		Lastmod    string `xml:"lastmod"`    // 2010-03-23, 2004-06-09, 2...
+		Changefreq string `xml:"changefreq"` // monthly, monthly, monthly...
+		Priority   string `xml:"priority"`   // 0.8, 0.8, 0.8, 0.8, 0.8, ...
+	} `xml:"url"`
+}
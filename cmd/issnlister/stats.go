@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/miku/issnlister/bitset"
+)
+
+// legacyPrefix is the two-digit prefix of a small pocket of old
+// registrations (8750, 8755, 8756), far outside of the active range.
+const legacyPrefix = 87
+
+// Bucket counts blocks with a density in [Min, Max] and their ISSN.
+type Bucket struct {
+	Min    int `json:"min"`
+	Max    int `json:"max"`
+	Blocks int `json:"blocks"`
+	ISSN   int `json:"issn"`
+}
+
+func (b Bucket) String() string {
+	if b.Min == b.Max {
+		return fmt.Sprintf("%d", b.Min)
+	}
+	return fmt.Sprintf("%d-%d", b.Min, b.Max)
+}
+
+func (b *Bucket) add(density int) {
+	if density >= b.Min && density <= b.Max {
+		b.Blocks++
+		b.ISSN += density
+	}
+}
+
+// Stats describes how the ISSN of a snapshot are distributed over 2-digit
+// prefixes and 4-digit blocks. Each block holds at most 1000 valid ISSN.
+type Stats struct {
+	Snapshot     string         `json:"snapshot"`
+	Count        int            `json:"count"`
+	Blocks       int            `json:"blocks"` // populated blocks
+	MaxBlock     string         `json:"max_active_block"`
+	ByPrefix     map[string]int `json:"by_prefix2"`
+	PrefixBlocks map[string]int `json:"blocks_by_prefix2"`
+	Histogram    []Bucket       `json:"density_histogram"`
+	Saturation   []Bucket       `json:"saturation"`
+	Legacy       map[string]int `json:"legacy_pocket"` // blocks in the legacy prefix
+	LegacyCount  int            `json:"legacy_count"`
+	BlockDensity []int          `json:"-"`
+}
+
+// computeStats counts ISSN per block and summarizes the blocks.
+func computeStats(s *bitset.Set) *Stats {
+	st := &Stats{
+		Count:        s.Len(),
+		ByPrefix:     make(map[string]int),
+		PrefixBlocks: make(map[string]int),
+		Legacy:       make(map[string]int),
+		BlockDensity: make([]int, 10000),
+		Saturation: []Bucket{
+			{Min: 900, Max: 1000},
+			{Min: 500, Max: 899},
+			{Min: 100, Max: 499},
+			{Min: 1, Max: 99},
+		},
+	}
+	st.Histogram = append(st.Histogram, Bucket{Min: 1, Max: 99})
+	for lo := 100; lo < 1000; lo += 100 {
+		st.Histogram = append(st.Histogram, Bucket{Min: lo, Max: lo + 99})
+	}
+	st.Histogram = append(st.Histogram, Bucket{Min: 1000, Max: 1000})
+	for b := 0; b < 10000; b++ {
+		n := s.BlockCount(b)
+		st.BlockDensity[b] = n
+		if n == 0 {
+			continue
+		}
+		st.Blocks++
+		st.ByPrefix[fmt.Sprintf("%02d", b/100)] += n
+		st.PrefixBlocks[fmt.Sprintf("%02d", b/100)]++
+		if b/100 == legacyPrefix {
+			st.Legacy[fmt.Sprintf("%04d", b)] = n
+			st.LegacyCount += n
+		} else {
+			st.MaxBlock = fmt.Sprintf("%04d", b)
+		}
+		for i := range st.Histogram {
+			st.Histogram[i].add(n)
+		}
+		for i := range st.Saturation {
+			st.Saturation[i].add(n)
+		}
+	}
+	return st
+}
+
+// writeText writes the stats as tables.
+func (st *Stats) writeText(w io.Writer) error {
+	fmt.Fprintf(w, "snapshot: %s\nissn: %d\nblocks: %d\nmax active block: %s\n",
+		st.Snapshot, st.Count, st.Blocks, st.MaxBlock)
+	if st.LegacyCount > 0 {
+		keys := sortedKeys(st.Legacy)
+		fmt.Fprintf(w, "legacy pocket: %d in %d blocks (", st.LegacyCount, len(keys))
+		for i, k := range keys {
+			if i > 0 {
+				fmt.Fprint(w, ", ")
+			}
+			fmt.Fprintf(w, "%s=%d", k, st.Legacy[k])
+		}
+		fmt.Fprintln(w, ")")
+	}
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "prefix\tissn\tblocks\t")
+	for _, k := range sortedKeys(st.ByPrefix) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t\n", k, st.ByPrefix[k], st.PrefixBlocks[k])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, t := range []struct {
+		title   string
+		buckets []Bucket
+	}{
+		{"density", st.Histogram},
+		{"saturation", st.Saturation},
+	} {
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(tw, "%s\tblocks\tissn\t\n", t.title)
+		for _, b := range t.buckets {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t\n", b, b.Blocks, b.ISSN)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// heatColor maps a block density to a color, from pale yellow over green
+// to dark blue; empty blocks are light gray.
+func heatColor(density int) string {
+	if density == 0 {
+		return "#eeeeee"
+	}
+	stops := [][3]float64{
+		{255, 255, 204},
+		{161, 218, 180},
+		{65, 182, 196},
+		{44, 127, 184},
+		{37, 52, 148},
+	}
+	t := float64(density) / 1000 * float64(len(stops)-1)
+	i := int(t)
+	if i >= len(stops)-1 {
+		i, t = len(stops)-2, float64(len(stops)-1)
+	}
+	f := t - float64(i)
+	var c [3]int
+	for k := range c {
+		c[k] = int(stops[i][k] + f*(stops[i+1][k]-stops[i][k]))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2])
+}
+
+// writeSVG draws the 10,000 blocks as a 100x100 grid, one row per 2-digit
+// prefix, colored by density.
+func (st *Stats) writeSVG(w io.Writer) error {
+	const (
+		cell   = 6
+		margin = 40
+		legend = 30
+		size   = 100 * cell
+	)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="10">`+"\n",
+		size+2*margin, size+2*margin+legend)
+	fmt.Fprintf(bw, `<text x="%d" y="%d" font-size="12">ISSN per 4-digit block, %s, %d ISSN</text>`+"\n",
+		margin, margin-20, html.EscapeString(st.Snapshot), st.Count)
+	for i := 0; i < 100; i += 10 {
+		fmt.Fprintf(bw, `<text x="%d" y="%d" text-anchor="end">%02d</text>`+"\n", margin-4, margin+i*cell+cell, i)
+		fmt.Fprintf(bw, `<text x="%d" y="%d">%02d</text>`+"\n", margin+i*cell, margin-4, i)
+	}
+	for b, n := range st.BlockDensity {
+		x, y := margin+(b%100)*cell, margin+(b/100)*cell
+		fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"><title>%04d: %d</title></rect>`+"\n",
+			x, y, cell, cell, heatColor(n), b, n)
+	}
+	// Legend.
+	y := margin + size + 10
+	for i := 0; i <= 10; i++ {
+		fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="10" fill="%s"/>`+"\n",
+			margin+i*30, y, 30, heatColor(i*100))
+		if i%5 == 0 {
+			fmt.Fprintf(bw, `<text x="%d" y="%d">%d</text>`+"\n", margin+i*30, y+22, i*100)
+		}
+	}
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
+
+// heatRamp are the characters of the ASCII heatmap, from empty blocks to
+// full ones.
+const heatRamp = " .:-=+*#%@"
+
+// heatChar maps a block density to a character of heatRamp; any populated
+// block gets at least a dot.
+func heatChar(density int) byte {
+	if density <= 0 {
+		return heatRamp[0]
+	}
+	i := 1 + (density-1)*(len(heatRamp)-1)/1000
+	return heatRamp[min(i, len(heatRamp)-1)]
+}
+
+// writeASCII draws the 10,000 blocks for a terminal, one line per 2-digit
+// prefix and one character per block, with the same layout as writeSVG.
+func (st *Stats) writeASCII(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "ISSN per 4-digit block, %s, %d ISSN\n\n", st.Snapshot, st.Count)
+	fmt.Fprint(bw, "   ")
+	for i := 0; i < 90; i += 10 {
+		fmt.Fprintf(bw, "%02d        ", i)
+	}
+	fmt.Fprintln(bw, "90")
+	line := make([]byte, 103)
+	for p := 0; p < 100; p++ {
+		copy(line, fmt.Sprintf("%02d ", p))
+		for i := 0; i < 100; i++ {
+			line[3+i] = heatChar(st.BlockDensity[p*100+i])
+		}
+		bw.Write(bytes.TrimRight(line, " "))
+		bw.WriteByte('\n')
+	}
+	fmt.Fprintf(bw, "\n'%c' empty", heatRamp[0])
+	for i := 1; i < len(heatRamp); i++ {
+		lo := (i-1)*1000/(len(heatRamp)-1) + 1
+		fmt.Fprintf(bw, ", '%c' %d+", heatRamp[i], lo)
+	}
+	fmt.Fprintln(bw)
+	return bw.Flush()
+}
+
+// runStats summarizes the distribution of ISSN in a snapshot, given as a
+// date of a cached list or a filename.
+//
+//	$ issnlister stats issn.tsv
+//	$ issnlister stats -svg 2026-02-16 > map.svg
+//	$ issnlister stats -ascii issn.tsv
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	var (
+		asJSON = fs.Bool("json", false, "print stats as JSON")
+		asSVG  = fs.Bool("svg", false, "print an SVG heatmap of block densities")
+		asText = fs.Bool("ascii", false, "print a heatmap of block densities for the terminal")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s stats [-json | -svg | -ascii] SNAPSHOT\n\n", appName)
+		fmt.Fprintln(fs.Output(), "SNAPSHOT is a date (2006-01-02) from the cache or a file.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	var formats int
+	for _, v := range []bool{*asJSON, *asSVG, *asText} {
+		if v {
+			formats++
+		}
+	}
+	if formats > 1 {
+		return fmt.Errorf("-json, -svg and -ascii are mutually exclusive")
+	}
+	s, err := loadSnapshot(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	st := computeStats(s)
+	st.Snapshot = fs.Arg(0)
+	switch {
+	case *asJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(st)
+	case *asSVG:
+		return st.writeSVG(os.Stdout)
+	case *asText:
+		return st.writeASCII(os.Stdout)
+	default:
+		bw := bufio.NewWriter(os.Stdout)
+		if err := st.writeText(bw); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
+}
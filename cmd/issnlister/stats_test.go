@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/issn"
+)
+
+func TestComputeStats(t *testing.T) {
+	s := bitset.New()
+	for block, n := range map[int]int{0: 5, 1: 1000, 150: 150, 8750: 3} {
+		for i := 0; i < n; i++ {
+			s.Add(issn.FromPrefix(block*1000 + i))
+		}
+	}
+	st := computeStats(s)
+	if st.Count != 1158 || st.Blocks != 4 {
+		t.Errorf("got %d ISSN in %d blocks, want 1158 in 4", st.Count, st.Blocks)
+	}
+	// The legacy pocket is not the frontier.
+	if st.MaxBlock != "0150" {
+		t.Errorf("max block: got %s, want 0150", st.MaxBlock)
+	}
+	if st.LegacyCount != 3 || st.Legacy["8750"] != 3 {
+		t.Errorf("legacy: got %d %v", st.LegacyCount, st.Legacy)
+	}
+	if st.ByPrefix["00"] != 1005 || st.PrefixBlocks["00"] != 2 || st.ByPrefix["01"] != 150 {
+		t.Errorf("by prefix: got %v %v", st.ByPrefix, st.PrefixBlocks)
+	}
+	for _, c := range []struct {
+		buckets []Bucket
+		name    string
+		blocks  int
+		issn    int
+	}{
+		{st.Histogram, "1-99", 2, 8},
+		{st.Histogram, "100-199", 1, 150},
+		{st.Histogram, "1000", 1, 1000},
+		{st.Histogram, "500-599", 0, 0},
+		{st.Saturation, "900-1000", 1, 1000},
+		{st.Saturation, "100-499", 1, 150},
+	} {
+		var found bool
+		for _, b := range c.buckets {
+			if b.String() != c.name {
+				continue
+			}
+			found = true
+			if b.Blocks != c.blocks || b.ISSN != c.issn {
+				t.Errorf("bucket %s: got %d blocks, %d ISSN, want %d, %d", c.name, b.Blocks, b.ISSN, c.blocks, c.issn)
+			}
+		}
+		if !found {
+			t.Errorf("bucket %s missing", c.name)
+		}
+	}
+
+	var buf strings.Builder
+	if err := st.writeASCII(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 105 {
+		t.Fatalf("got %d lines, want 105", len(lines))
+	}
+	for i, want := range map[int]string{
+		3:      "00 .@",
+		4:      "01 " + strings.Repeat(" ", 50) + ":",
+		5:      "02",
+		3 + 87: "87 " + strings.Repeat(" ", 50) + ".",
+	} {
+		if lines[i] != want {
+			t.Errorf("line %d: got %q, want %q", i, lines[i], want)
+		}
+	}
+
+	buf.Reset()
+	if err := st.writeSVG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "<rect"); n != 10000+11 {
+		t.Errorf("svg: got %d rects, want 10011", n)
+	}
+	buf.Reset()
+	if err := st.writeText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "max active block: 0150") || !strings.Contains(buf.String(), "legacy pocket: 3 in 1 blocks (8750=3)") {
+		t.Errorf("text: got %s", buf.String())
+	}
+}
+
+func TestHeat(t *testing.T) {
+	var cases = []struct {
+		density int
+		char    byte
+		color   string
+	}{
+		{0, ' ', "#eeeeee"},
+		{1, '.', ""},
+		{112, '.', ""},
+		{113, ':', ""},
+		{500, '+', "#41b6c4"},
+		{1000, '@', "#253494"},
+	}
+	for _, c := range cases {
+		if got := heatChar(c.density); got != c.char {
+			t.Errorf("heatChar(%d): got %q, want %q", c.density, got, c.char)
+		}
+		if got := heatColor(c.density); c.color != "" && got != c.color {
+			t.Errorf("heatColor(%d): got %s, want %s", c.density, got, c.color)
+		}
+	}
+}
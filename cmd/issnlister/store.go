@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/probecache"
+	"github.com/miku/issnlister/record"
+	"github.com/miku/issnlister/store"
+	log "github.com/sirupsen/logrus"
+)
+
+// importHarvest adds the records of a harvest file to a store, dated with
+// the modification time of the file.
+func importHarvest(s *store.Store, filename string) (n int, err error) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return 0, err
+	}
+	err = eachHarvestLine(filename, func(v issn.ISSN, _ *record.Record, line []byte) error {
+		n++
+		return s.Put(v, fi.ModTime(), line)
+	})
+	return n, err
+}
+
+// importProbeCache adds the saved bodies of an issnprobe cache directory or
+// database to a store, dated with the time of the probe.
+func importProbeCache(s *store.Store, path string) (n int, err error) {
+	c, err := probecache.OpenReadOnly(path)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	err = c.Walk(func(pr *probecache.Result) error {
+		if !pr.Registered {
+			return nil
+		}
+		body, err := c.Body(pr.ISSN)
+		if errors.Is(err, probecache.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		v, err := issn.Parse(pr.ISSN)
+		if err != nil {
+			return nil
+		}
+		n++
+		return s.Put(v, pr.FetchedAt, body)
+	})
+	return n, err
+}
+
+// storeInfo writes counts and sizes of a store.
+func storeInfo(w io.Writer, s *store.Store) error {
+	var versions int
+	for _, v := range s.Keys() {
+		versions += len(s.Versions(v))
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "issn\t%d\n", s.Len())
+	fmt.Fprintf(tw, "versions\t%d\n", versions)
+	fmt.Fprintf(tw, "bodies\t%d\n", s.Bodies())
+	fmt.Fprintf(tw, "size\t%0.2fMB\n", float64(s.Size())/1048576)
+	if n := s.Bodies(); n > 0 {
+		fmt.Fprintf(tw, "bytes per body\t%d\n", s.Size()/int64(n))
+	}
+	return tw.Flush()
+}
+
+// runStore manages a record store of harvested JSON-LD bodies.
+//
+//	$ issnlister store import data.store data.ndj.gz ~/.cache/issnprobe
+//	$ issnlister store get data.store 0378-5955
+//	$ issnlister store cat data.store > data.ndj
+//	$ issnlister store compact -keep 3 data.store
+func runStore(args []string) error {
+	fs := flag.NewFlagSet("store", flag.ExitOnError)
+	var (
+		keep     = fs.Int("keep", 0, "compact: keep at most this many versions per ISSN (0 = all)")
+		codec    = fs.String("codec", "zstd", "compression of new blocks: zstd or gzip")
+		versions = fs.Bool("versions", false, "get: list all versions instead of the latest body")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s store [flags] COMMAND FILE [ARGS]\n\n", appName)
+		fmt.Fprintln(fs.Output(), "Commands:")
+		fmt.Fprintln(fs.Output(), "  info FILE             counts and size")
+		fmt.Fprintln(fs.Output(), "  get FILE ISSN         latest body of a record")
+		fmt.Fprintln(fs.Output(), "  cat FILE              latest bodies as NDJSON, in ISSN order")
+		fmt.Fprintln(fs.Output(), "  import FILE SRC ...   add harvest files, issnprobe cache directories or databases")
+		fmt.Fprintln(fs.Output(), "  compact FILE          rewrite in ISSN order, dropping unused bodies")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	var (
+		cmd, filename = fs.Arg(0), fs.Arg(1)
+		s             *store.Store
+		err           error
+	)
+	switch cmd {
+	case "info", "get", "cat":
+		s, err = store.OpenReadOnly(filename)
+	case "import", "compact":
+		s, err = store.Open(filename)
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+	if err != nil {
+		return err
+	}
+	switch *codec {
+	case "zstd":
+		s.Codec = store.Zstd
+	case "gzip":
+		s.Codec = store.Gzip
+	default:
+		s.Close()
+		return fmt.Errorf("unknown codec: %s", *codec)
+	}
+	bw := bufio.NewWriter(os.Stdout)
+	defer bw.Flush()
+	switch cmd {
+	case "info":
+		defer s.Close()
+		return storeInfo(bw, s)
+	case "get":
+		defer s.Close()
+		if fs.NArg() != 3 {
+			return errors.New("get requires an ISSN")
+		}
+		v, err := issn.Parse(fs.Arg(2))
+		if err != nil {
+			return err
+		}
+		if *versions {
+			for _, ver := range s.Versions(v) {
+				fmt.Fprintf(bw, "%s\t%s\t%x\n", ver.ISSN, ver.Time.Format(time.RFC3339), ver.Hash)
+			}
+			return nil
+		}
+		_, body, err := s.Get(v)
+		if err != nil {
+			return err
+		}
+		bw.Write(bytes.TrimRight(body, "\n"))
+		return bw.WriteByte('\n')
+	case "cat":
+		defer s.Close()
+		var buf bytes.Buffer
+		return s.Iter(func(ver store.Version, body []byte) error {
+			buf.Reset()
+			if err := json.Compact(&buf, body); err != nil {
+				log.Warnf("%s: %v", ver.ISSN, err)
+				return nil
+			}
+			buf.WriteByte('\n')
+			_, err := bw.Write(buf.Bytes())
+			return err
+		})
+	case "import":
+		for _, src := range fs.Args()[2:] {
+			var n int
+			fi, err := os.Stat(src)
+			if err == nil && (fi.IsDir() || probecache.IsDB(src)) {
+				n, err = importProbeCache(s, src)
+			} else if err == nil {
+				n, err = importHarvest(s, src)
+			}
+			if err != nil {
+				s.Close()
+				return err
+			}
+			log.Printf("imported %d records from %s", n, src)
+		}
+		if err := s.Close(); err != nil {
+			return err
+		}
+		if s, err = store.OpenReadOnly(filename); err != nil {
+			return err
+		}
+		defer s.Close()
+		return storeInfo(bw, s)
+	default: // compact
+		before := s.Size()
+		if err := s.Compact(*keep); err != nil {
+			return err
+		}
+		if s, err = store.OpenReadOnly(filename); err != nil {
+			return err
+		}
+		defer s.Close()
+		log.Printf("compacted %s from %0.2fMB to %0.2fMB", filename,
+			float64(before)/1048576, float64(s.Size())/1048576)
+		return storeInfo(bw, s)
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/issn"
+)
+
+// Frontier planning, see section 1.4 of notes/2026-04-19-approximation.md.
+// Within a block, national centres allocate near-sequentially, so the
+// next registrations are most likely a few typical gaps after the highest
+// known ISSN. Each block gets a gap distribution, fitted from the gaps
+// between its most recent known ISSN and smoothed towards the global
+// distribution. The probability that the candidate d positions after the
+// highest known ISSN has been allocated is
+//
+//	P(d) = Σ_k P(S_k = d) · P(N ≥ k)
+//
+// where S_k is the sum of k gaps and N the number of allocations in the
+// block since the snapshot, Poisson with the block's growth rate from
+// prior snapshots. Without prior snapshots, N is unbounded and P(d) is
+// the renewal density. Untouched blocks after the highest populated one
+// open at the global block-opening rate.
+
+const (
+	maxGap     = 64   // longer gaps are counted as maxGap
+	recentGaps = 50   // gaps per block used for fitting, the most recent allocations
+	gapPrior   = 10.0 // weight of the global gap distribution per block
+	minScore   = 1e-3 // candidates below are not probed
+	// defaultOpenRate is the block-opening rate without prior snapshots,
+	// about five blocks per month.
+	defaultOpenRate = 5.0 / 30
+)
+
+// snapshot is a known set at a point in time.
+type snapshot struct {
+	set  *bitset.Set
+	date time.Time
+}
+
+// loadSnapshots reads prior snapshots, oldest first.
+func loadSnapshots(filenames []string) ([]snapshot, error) {
+	var result []snapshot
+	for _, fn := range filenames {
+		t, err := issn.SnapshotFileDate(fn)
+		if err != nil {
+			return nil, err
+		}
+		s, err := loadKnown(fn)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+		log.Printf("history: %s, %d ISSN, %s", fn, s.Len(), t.Format("2006-01-02"))
+		result = append(result, snapshot{set: s, date: t})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].date.Before(result[j].date) })
+	return result, nil
+}
+
+// gapDist is a distribution over gaps 1..maxGap, index 0 is unused.
+type gapDist [maxGap + 1]float64
+
+// blockIndexes returns the positions 0..999 of known ISSN in a block.
+func blockIndexes(known *bitset.Set, block int) []int {
+	var idx []int
+	for i := 0; i < 1000; i++ {
+		if known.Contains(issn.FromPrefix(block*1000 + i)) {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// countGaps adds the most recent gaps between known positions to counts.
+func countGaps(idx []int, counts *gapDist) (n int) {
+	start := len(idx) - recentGaps - 1
+	if start < 0 {
+		start = 0
+	}
+	for i := start + 1; i < len(idx); i++ {
+		g := min(idx[i]-idx[i-1], maxGap)
+		counts[g]++
+		n++
+	}
+	return n
+}
+
+// normalize turns counts into a distribution, uniform if empty.
+func (g *gapDist) normalize() {
+	var sum float64
+	for _, v := range g[1:] {
+		sum += v
+	}
+	for i := 1; i <= maxGap; i++ {
+		if sum == 0 {
+			g[i] = 1.0 / maxGap
+		} else {
+			g[i] /= sum
+		}
+	}
+}
+
+// renewal returns the probability that position d (1..n) is allocated,
+// given an allocation at 0 and unlimited time.
+func renewal(g *gapDist, n int) []float64 {
+	u := make([]float64, n+1)
+	u[0] = 1
+	for d := 1; d <= n; d++ {
+		for k := 1; k <= maxGap && k <= d; k++ {
+			u[d] += g[k] * u[d-k]
+		}
+	}
+	return u
+}
+
+// bounded returns the probability that position d (1..n) is allocated,
+// given an allocation at 0 and a Poisson number of allocations with mean
+// mu since.
+func bounded(g *gapDist, n int, mu float64) []float64 {
+	if mu > float64(n) || mu > 200 {
+		// More allocations than positions, practically unbounded.
+		return renewal(g, n)
+	}
+	var (
+		p    = make([]float64, n+1)
+		dist = make([]float64, n+1) // P(S_k = d)
+		pmf  = math.Exp(-mu)        // P(N = k-1)
+		tail = 1 - pmf              // P(N ≥ k)
+	)
+	dist[0] = 1
+	lo, hi := 0, 0 // support of dist, ignoring negligible mass
+	for k := 1; k <= n && tail > 1e-4 && lo <= n; k++ {
+		next := make([]float64, n+1)
+		for d := lo; d <= hi; d++ {
+			if dist[d] == 0 {
+				continue
+			}
+			for j := 1; j <= maxGap && d+j <= n; j++ {
+				next[d+j] += g[j] * dist[d]
+			}
+		}
+		dist, lo, hi = next, n+1, 0
+		for d := range dist {
+			if dist[d] < 1e-9 {
+				dist[d] = 0
+				continue
+			}
+			lo, hi = min(lo, d), max(hi, d)
+			p[d] += dist[d] * tail
+		}
+		pmf *= mu / float64(k)
+		tail -= pmf
+	}
+	return p
+}
+
+// poissonTail returns P(N ≥ k) for N Poisson with mean mu.
+func poissonTail(mu float64, k int) float64 {
+	pmf, cdf := math.Exp(-mu), 0.0
+	for j := 0; j < k; j++ {
+		cdf += pmf
+		pmf *= mu / float64(j+1)
+	}
+	return math.Max(0, 1-cdf)
+}
+
+// scored is a candidate with its predicted hit probability.
+type scored struct {
+	issn  string
+	score float64
+}
+
+// frontierCandidates ranks candidates after the highest known ISSN of
+// every block and in untouched blocks after the highest populated one by
+// predicted hit probability.
+func frontierCandidates(known *bitset.Set, spec candidateSpec) []string {
+	var (
+		global   gapDist
+		idx      = make(map[int][]int)
+		lastOpen = -1 // highest populated block in range
+	)
+	for p := spec.prefixMin; p <= spec.prefixMax; p++ {
+		if known.BlockCount(p) == 0 {
+			continue
+		}
+		idx[p] = blockIndexes(known, p)
+		countGaps(idx[p], &global)
+		lastOpen = p
+	}
+	global.normalize()
+	// Time since the snapshot and growth from prior snapshots.
+	days := math.Max(1, spec.now.Sub(spec.knownDate).Hours()/24)
+	var (
+		span     float64 // days covered by history
+		growth   = make(map[int]int)
+		openRate = defaultOpenRate
+	)
+	if len(spec.history) > 0 {
+		oldest := spec.history[0]
+		span = spec.knownDate.Sub(oldest.date).Hours() / 24
+		var opened int
+		for p := spec.prefixMin; p <= spec.prefixMax; p++ {
+			n, m := known.BlockCount(p), oldest.set.BlockCount(p)
+			growth[p] = max(0, n-m)
+			if n > 0 && m == 0 {
+				opened++
+			}
+		}
+		if span >= 1 {
+			openRate = float64(opened) / span
+		} else {
+			log.Printf("history is not older than the snapshot, ignoring it")
+			span = 0
+		}
+	}
+	log.Printf("frontier: %.0f days since snapshot, opening %.2f blocks/month", days, openRate*30)
+	var (
+		result   []scored
+		expected float64
+	)
+	add := func(block, from int, p []float64, weight float64) {
+		for d := 1; from+d < 1000 && d < len(p); d++ {
+			s := p[d] * weight
+			if s < minScore {
+				continue
+			}
+			result = append(result, scored{issn.FromPrefix(block*1000 + from + d).String(), s})
+			expected += s
+		}
+	}
+	for p := spec.prefixMin; p <= lastOpen; p++ {
+		ix := idx[p]
+		if len(ix) == 0 {
+			continue
+		}
+		last := ix[len(ix)-1]
+		if last == 999 {
+			continue
+		}
+		var g gapDist
+		n := countGaps(ix, &g)
+		for k := 1; k <= maxGap; k++ {
+			g[k] = (g[k] + gapPrior*global[k]) / (float64(n) + gapPrior)
+		}
+		if span > 0 {
+			// Smoothed, so that dormant blocks keep a small chance.
+			mu := (float64(growth[p]) + 0.1) / span * days
+			add(p, last, bounded(&g, 999-last, mu), 1)
+		} else {
+			add(p, last, renewal(&g, 999-last), 1)
+		}
+	}
+	// Untouched blocks, allocated from their start; the j-th one is open,
+	// if at least j blocks were opened since the snapshot. Without any
+	// populated block in range, they start at the lower end of the range.
+	var (
+		u     = renewal(&global, 1000)
+		first = max(lastOpen, spec.prefixMin-1)
+	)
+	for j := 1; first+j <= spec.prefixMax; j++ {
+		w := poissonTail(openRate*days, j)
+		if w < minScore {
+			break
+		}
+		add(first+j, -1, u, w)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].score != result[j].score {
+			return result[i].score > result[j].score
+		}
+		return result[i].issn < result[j].issn
+	})
+	log.Printf("frontier: %d candidates, %.1f expected hits", len(result), expected)
+	out := make([]string, len(result))
+	for i, r := range result {
+		out[i] = r.issn
+	}
+	return out
+}
+
+// missStopper skips the rest of a block after a number of consecutive
+// misses. It is safe for concurrent use.
+type missStopper struct {
+	mu      sync.Mutex
+	n       int
+	misses  map[string]int // by block
+	skipped int
+}
+
+func newMissStopper(n int) *missStopper {
+	return &missStopper{n: n, misses: make(map[string]int)}
+}
+
+// skip reports whether the block of a candidate is done.
+func (s *missStopper) skip(c string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.misses[c[:4]] >= s.n {
+		s.skipped++
+		return true
+	}
+	return false
+}
+
+// observe counts misses, any registration resets the count.
+func (s *missStopper) observe(o outcome) {
+	if o.err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if o.result.Registered || o.result.Legacy {
+		s.misses[o.issn[:4]] = 0
+	} else {
+		s.misses[o.issn[:4]]++
+	}
+}
+
+// stopped returns the number of blocks given up.
+func (s *missStopper) stopped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int
+	for _, v := range s.misses {
+		if v >= s.n {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/probecache"
+)
+
+// fixedGap returns a distribution with all mass on a single gap.
+func fixedGap(k int) *gapDist {
+	var g gapDist
+	g[k] = 1
+	return &g
+}
+
+func TestRenewal(t *testing.T) {
+	var cases = []struct {
+		gap  int
+		d    int
+		want float64
+	}{
+		{1, 1, 1},
+		{1, 7, 1},
+		{2, 1, 0},
+		{2, 4, 1},
+		{3, 5, 0},
+		{3, 6, 1},
+	}
+	for _, c := range cases {
+		u := renewal(fixedGap(c.gap), 10)
+		if u[c.d] != c.want {
+			t.Errorf("gap %d: u[%d] = %v, want %v", c.gap, c.d, u[c.d], c.want)
+		}
+	}
+	var g gapDist
+	g.normalize()
+	if u := renewal(&g, 200); math.Abs(u[200]-2.0/(maxGap+1)) > 1e-3 {
+		t.Errorf("uniform gaps: got %v, want the inverse mean gap", u[200])
+	}
+}
+
+func TestBounded(t *testing.T) {
+	var cases = []struct {
+		gap int
+		mu  float64
+		d   int
+	}{
+		{1, 0.5, 1},
+		{1, 3, 2},
+		{1, 3, 5},
+		{2, 3, 4},
+		{2, 3, 6},
+	}
+	for _, c := range cases {
+		// With a fixed gap, position d is the (d/gap)-th allocation.
+		p := bounded(fixedGap(c.gap), 20, c.mu)
+		want := poissonTail(c.mu, c.d/c.gap)
+		if math.Abs(p[c.d]-want) > 1e-3 {
+			t.Errorf("gap %d, mu %v: p[%d] = %v, want %v", c.gap, c.mu, c.d, p[c.d], want)
+		}
+		if p[c.d-1] != 0 && c.gap > 1 {
+			t.Errorf("gap %d: p[%d] = %v, want 0", c.gap, c.d-1, p[c.d-1])
+		}
+	}
+	// More allocations than positions, the renewal density.
+	p, u := bounded(fixedGap(1), 10, 50), renewal(fixedGap(1), 10)
+	for d := 1; d <= 10; d++ {
+		if p[d] != u[d] {
+			t.Errorf("unbounded: p[%d] = %v, want %v", d, p[d], u[d])
+		}
+	}
+}
+
+func TestFrontierCandidates(t *testing.T) {
+	var (
+		now   = time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+		known = bitset.New()
+	)
+	// Block 5 allocated every other position up to 20.
+	for i := 0; i <= 20; i += 2 {
+		known.Add(issn.FromPrefix(5000 + i))
+	}
+	var cases = []struct {
+		about    string
+		min, max int
+		first    string // Prefix of the best candidate.
+	}{
+		{"after the highest in block", 5, 7, "0005-022"},
+		{"untouched blocks only", 100, 102, "0100-"},
+		{"untouched blocks after the highest populated", 3, 8, "0005-022"},
+	}
+	for _, c := range cases {
+		spec := candidateSpec{prefixMin: c.min, prefixMax: c.max, knownDate: now.Add(-30 * 24 * time.Hour), now: now}
+		cs := frontierCandidates(known, spec)
+		if len(cs) == 0 {
+			t.Fatalf("%s: no candidates", c.about)
+		}
+		if !strings.HasPrefix(cs[0], c.first) {
+			t.Errorf("%s: first candidate %s, want %s*", c.about, cs[0], c.first)
+		}
+		for _, v := range cs {
+			b := issn.MustParse(v).Block()
+			if b < c.min || b > c.max {
+				t.Errorf("%s: candidate %s outside blocks %d-%d", c.about, v, c.min, c.max)
+				break
+			}
+			if known.ContainsString(v) {
+				t.Errorf("%s: known candidate %s", c.about, v)
+			}
+		}
+	}
+}
+
+func TestMissStopper(t *testing.T) {
+	var (
+		s   = newMissStopper(2)
+		hit = &probecache.Result{Registered: true}
+		mis = &probecache.Result{}
+	)
+	var steps = []struct {
+		o    outcome
+		skip string
+		want bool
+	}{
+		{outcome{issn: "0000-0019", result: mis}, "0000-0027", false},
+		{outcome{issn: "0000-0027", err: errors.New("timeout")}, "0000-0035", false},
+		{outcome{issn: "0000-0035", result: hit}, "0000-0043", false},
+		{outcome{issn: "0000-0043", result: mis}, "0000-0051", false},
+		{outcome{issn: "0000-0051", result: mis}, "0000-006X", true},
+		{outcome{issn: "0001-0015", result: mis}, "0001-0023", false},
+	}
+	for i, st := range steps {
+		s.observe(st.o)
+		if got := s.skip(st.skip); got != st.want {
+			t.Errorf("step %d: skip %s = %v, want %v", i, st.skip, got, st.want)
+		}
+	}
+	if n := s.stopped(); n != 1 {
+		t.Errorf("stopped: got %d, want 1", n)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket shared by all workers, which enforces the
+// global request budget: one token per interval, at most burst tokens
+// saved up. It is implemented as a virtual schedule (GCRA): each request
+// reserves the next free slot.
+//
+// The interval adapts to the portal: throttle doubles it (up to max) and
+// pauses everyone until a Retry-After deadline, healthy shrinks it back
+// towards the configured base interval.
+type limiter struct {
+	mu         sync.Mutex
+	base       time.Duration // configured interval
+	interval   time.Duration // current interval
+	max        time.Duration // ceiling for throttling
+	burst      int
+	next       time.Time // next free slot
+	pauseUntil time.Time
+	requests   int // number of granted requests
+	throttled  int // number of throttle events
+}
+
+func newLimiter(interval, max time.Duration, burst int) *limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	if max < interval {
+		max = interval
+	}
+	return &limiter{base: interval, interval: interval, max: max, burst: burst}
+}
+
+// wait blocks until a request may be sent.
+func (l *limiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	t := l.next
+	// Unused tokens: allow the schedule to lag behind by burst-1 slots.
+	if earliest := now.Add(-time.Duration(l.burst-1) * l.interval); t.Before(earliest) {
+		t = earliest
+	}
+	if t.Before(l.pauseUntil) {
+		t = l.pauseUntil
+	}
+	l.next = t.Add(l.interval)
+	l.requests++
+	l.mu.Unlock()
+	return sleepCtx(ctx, time.Until(t))
+}
+
+// throttle slows down after a 429 or 5xx. If the server asked us to wait
+// (Retry-After), nobody sends a request before that.
+func (l *limiter) throttle(retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.throttled++
+	l.interval *= 2
+	if l.interval > l.max {
+		l.interval = l.max
+	}
+	if retryAfter > 0 {
+		if t := time.Now().Add(retryAfter); t.After(l.pauseUntil) {
+			l.pauseUntil = t
+		}
+	}
+	// Drop saved up tokens.
+	if now := time.Now(); l.next.Before(now) {
+		l.next = now
+	}
+}
+
+// healthy speeds up again after a successful response.
+func (l *limiter) healthy() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.interval > l.base {
+		l.interval = l.interval * 9 / 10
+		if l.interval < l.base {
+			l.interval = l.base
+		}
+	}
+}
+
+// stats returns the number of granted requests, throttle events and the
+// current interval.
+func (l *limiter) stats() (requests, throttled int, interval time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.requests, l.throttled, l.interval
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// timedWait returns the time a wait blocked.
+func timedWait(t *testing.T, l *limiter) time.Duration {
+	t.Helper()
+	started := time.Now()
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return time.Since(started)
+}
+
+func TestLimiterBurst(t *testing.T) {
+	const (
+		interval = 40 * time.Millisecond
+		slack    = 20 * time.Millisecond
+	)
+	l := newLimiter(interval, time.Second, 3)
+	// Saved up tokens are granted at once, then one per interval.
+	for i := 0; i < 3; i++ {
+		if d := timedWait(t, l); d > slack {
+			t.Errorf("request %d: waited %v, want none", i, d)
+		}
+	}
+	for i := 3; i < 5; i++ {
+		if d := timedWait(t, l); d < interval-slack/2 || d > interval+slack {
+			t.Errorf("request %d: waited %v, want about %v", i, d, interval)
+		}
+	}
+	// Tokens accrue again while idle.
+	time.Sleep(3 * interval)
+	if d := timedWait(t, l); d > slack {
+		t.Errorf("after idle: waited %v, want none", d)
+	}
+	if n, _, _ := l.stats(); n != 6 {
+		t.Errorf("got %d requests, want 6", n)
+	}
+}
+
+func TestLimiterThrottle(t *testing.T) {
+	const interval = 10 * time.Millisecond
+	l := newLimiter(interval, 50*time.Millisecond, 1)
+	var cases = []struct {
+		throttle bool
+		want     time.Duration
+	}{
+		{true, 20 * time.Millisecond},
+		{true, 40 * time.Millisecond},
+		{true, 50 * time.Millisecond}, // At most max.
+		{false, 45 * time.Millisecond},
+		{false, 40500 * time.Microsecond},
+	}
+	for i, c := range cases {
+		if c.throttle {
+			l.throttle(0)
+		} else {
+			l.healthy()
+		}
+		if _, _, got := l.stats(); got != c.want {
+			t.Errorf("step %d: interval %v, want %v", i, got, c.want)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		l.healthy()
+	}
+	if _, throttled, got := l.stats(); got != interval || throttled != 3 {
+		t.Errorf("got interval %v after %d throttles, want %v after 3", got, throttled, interval)
+	}
+	// Nobody is let through before Retry-After.
+	const retryAfter = 60 * time.Millisecond
+	l.throttle(retryAfter)
+	if d := timedWait(t, l); d < retryAfter-5*time.Millisecond {
+		t.Errorf("waited %v, want at least %v", d, retryAfter)
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	l := newLimiter(time.Hour, time.Hour, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := l.wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := l.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
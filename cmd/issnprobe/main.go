@@ -8,8 +8,9 @@
 //	curl -H "Accept: application/ld+json" \
 //	     https://portal.issn.org/resource/ISSN/<NNNN-NNNC>
 //
-// We use this endpoint, serially, with a minimum inter-request delay,
-// exponential backoff on 429/5xx, and a per-ISSN disk cache. See
+// We use this endpoint with a small pool of workers behind a shared rate
+// limiter (-delay, -burst), which slows down for everyone on 429/5xx and
+// honours Retry-After, and a per-ISSN disk cache. See
 // notes/2026-04-19-approximation.md for the estimation framework.
 package main
 
@@ -17,7 +18,6 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
-	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -35,6 +35,11 @@ import (
 	"time"
 
 	"github.com/adrg/xdg"
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/probecache"
+	"github.com/miku/issnlister/sniff"
+	"github.com/miku/issnlister/store"
 )
 
 const (
@@ -45,12 +50,14 @@ const (
 	// schemaVersion is stamped into every cached Result. Bump whenever
 	// classify() changes; older cache entries are then re-classified
 	// from the saved JSON-LD body on next read (no network hit).
-	schemaVersion = 2
+	schemaVersion = 3
 )
 
 // classify inspects an HTTP response and decides whether an ISSN is
 // actually registered with bibliographic metadata, merely acknowledged
-// by the portal as "legacy" (no data), or not found at all.
+// by the portal as "legacy" (no data), or not found at all. Alternate
+// serializations like RDF/XML are expected to be converted to JSON-LD
+// (sniff.Normalize) before.
 //
 // Some ISSN return 200 with a "No data available" page — often via an
 // HTML fallback even when JSON-LD is requested. These should NOT count
@@ -59,15 +66,19 @@ func classify(status int, body []byte) (registered, legacy bool) {
 	if status != http.StatusOK || len(body) == 0 {
 		return false, false
 	}
-	s := string(body)
-	if strings.Contains(s, "No data available") {
+	switch sniff.Detect(body) {
+	case sniff.HTMLStub:
 		return false, true
-	}
-	if !strings.Contains(s, `"@context"`) {
-		// not JSON-LD (some HTML page we don't recognise) — stay
+	case sniff.JSONLD:
+	default:
+		// not JSON-LD (some page we don't recognise) — stay
 		// conservative and don't count as registered
 		return false, false
 	}
+	s := string(body)
+	if strings.Contains(s, "No data available") {
+		return false, true
+	}
 	// Positive metadata signals in JSON-LD responses from issn.org.
 	// A real record carries at least one title/name/type marker.
 	markers := []string{
@@ -91,93 +102,52 @@ func classify(status int, body []byte) (registered, legacy bool) {
 
 // ----- ISSN math ----------------------------------------------------------
 
-// issnCheckDigit computes the check digit for a 7-digit prefix. Returns
-// the empty string if the input is not 7 ASCII digits.
-func issnCheckDigit(seven string) string {
-	if len(seven) != 7 {
-		return ""
-	}
-	sum := 0
-	for i := 0; i < 7; i++ {
-		c := seven[i]
-		if c < '0' || c > '9' {
-			return ""
-		}
-		sum += int(c-'0') * (8 - i)
-	}
-	mod := sum % 11
-	cd := 0
-	if mod != 0 {
-		cd = 11 - mod
-	}
-	if cd == 10 {
-		return "X"
-	}
-	return strconv.Itoa(cd)
-}
-
-// formatISSN turns a 7-digit prefix into a hyphenated ISSN with check
-// digit, e.g. "3134164" -> "3134-1640".
-func formatISSN(seven string) string {
-	cd := issnCheckDigit(seven)
-	if cd == "" {
-		return ""
-	}
-	return seven[:4] + "-" + seven[4:] + cd
-}
-
 // blockCandidates returns the 1000 checksum-valid ISSN inside a 4-digit
 // prefix, in ascending numeric order.
 func blockCandidates(prefix4 string) []string {
+	block, err := strconv.Atoi(prefix4)
+	if err != nil || block < 0 || block > 9999 {
+		return nil
+	}
 	out := make([]string, 0, 1000)
 	for i := 0; i < 1000; i++ {
-		seven := fmt.Sprintf("%s%03d", prefix4, i)
-		if s := formatISSN(seven); s != "" {
-			out = append(out, s)
-		}
+		out = append(out, issn.FromPrefix(block*1000+i).String())
 	}
 	return out
 }
 
 // ----- known set ----------------------------------------------------------
 
-func loadKnown(path string) (map[string]struct{}, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
+// loadKnown reads the known set, either from a list with one ISSN per line
+// or from a serialized bitset. Invalid values in a list are logged and
+// skipped.
+func loadKnown(path string) (*bitset.Set, error) {
+	s, err := bitset.ReadFile(path)
+	if bitset.IsInvalid(err) {
+		log.Printf("%s: %v", path, err)
+		return s, nil
 	}
-	defer f.Close()
-	known := make(map[string]struct{}, 3_000_000)
-	sc := bufio.NewScanner(f)
-	sc.Buffer(make([]byte, 0, 1<<20), 1<<24)
-	for sc.Scan() {
-		line := strings.TrimSpace(sc.Text())
-		if line != "" {
-			known[line] = struct{}{}
-		}
-	}
-	return known, sc.Err()
+	return s, err
 }
 
 // ----- cache + HTTP -------------------------------------------------------
 
-// Result is the per-ISSN cache record.
-type Result struct {
-	ISSN          string    `json:"issn"`
-	Status        int       `json:"status"`
-	Registered    bool      `json:"registered"`
-	Legacy        bool      `json:"legacy,omitempty"`
-	SchemaVersion int       `json:"schema_version"`
-	FetchedAt     time.Time `json:"fetched_at"`
-	Error         string    `json:"error,omitempty"`
-}
-
 type Prober struct {
 	client   *http.Client
-	cacheDir string
+	cache    probecache.Cache
 	ua       string
-	delay    time.Duration
+	limiter  *limiter
 	saveBody bool
+	// maxAge is the age after which a cached result is checked again,
+	// with a conditional request; 0 means cached results never expire.
+	maxAge time.Duration
+
+	// responses counts the kinds of response bodies seen
+	responses sniff.Counter
+
+	// runStarted and seed are recorded with every fetched result
+	runStarted time.Time
+	seed       int64
 
 	// backoff state
 	minBackoff time.Duration
@@ -185,88 +155,102 @@ type Prober struct {
 	maxRetries int
 }
 
-func (p *Prober) cachePath(issn string) string {
-	return filepath.Join(p.cacheDir, issn[:4], issn+".json")
-}
-
-func (p *Prober) bodyPath(issn string) string {
-	return filepath.Join(p.cacheDir, issn[:4], issn+".jsonld")
-}
-
-func (p *Prober) readCache(issn string) (*Result, bool) {
-	b, err := os.ReadFile(p.cachePath(issn))
+func (p *Prober) readCache(issn string) (*probecache.Result, bool) {
+	r, err := p.cache.Result(issn)
 	if err != nil {
 		return nil, false
 	}
-	var r Result
-	if err := json.Unmarshal(b, &r); err != nil {
-		return nil, false
-	}
 	// Auto-upgrade stale cache entries when we have the saved body
 	// on disk (old classifier called "No data available" pages
 	// registered). No network access involved.
 	if r.SchemaVersion < schemaVersion {
-		if body, ferr := os.ReadFile(p.bodyPath(issn)); ferr == nil {
+		if body, ferr := p.cache.Body(issn); ferr == nil {
 			reg, leg := classify(r.Status, body)
 			r.Registered = reg
 			r.Legacy = leg
 		}
 		r.SchemaVersion = schemaVersion
-		if nb, merr := json.Marshal(&r); merr == nil {
-			_ = os.WriteFile(p.cachePath(issn), nb, 0o644)
-		}
+		_ = p.cache.PutResult(r)
 	}
-	return &r, true
+	return r, true
 }
 
-func (p *Prober) writeCache(r *Result, body []byte) error {
-	dir := filepath.Dir(p.cachePath(r.ISSN))
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return err
+// expired returns true, if a cached result should be checked again.
+func (p *Prober) expired(r *probecache.Result) bool {
+	return p.maxAge > 0 && time.Since(r.Checked()) > p.maxAge
+}
+
+// upgrade re-classifies a result from an older schema in memory, using
+// the saved body. It reports false for a stale 200 result without body,
+// whose classification cannot be trusted.
+func upgrade(c probecache.Cache, r *probecache.Result) bool {
+	if r.SchemaVersion >= schemaVersion {
+		return true
 	}
-	b, err := json.Marshal(r)
+	body, err := c.Body(r.ISSN)
 	if err != nil {
-		return err
+		return r.Status != http.StatusOK
 	}
-	if err := os.WriteFile(p.cachePath(r.ISSN), b, 0o644); err != nil {
+	r.Registered, r.Legacy = classify(r.Status, body)
+	return true
+}
+
+func (p *Prober) writeCache(r *probecache.Result, body []byte) error {
+	if err := p.cache.PutResult(r); err != nil {
 		return err
 	}
-	if p.saveBody && r.Registered && len(body) > 0 {
-		_ = os.WriteFile(p.bodyPath(r.ISSN), body, 0o644)
+	if !p.saveBody || !r.Registered || len(body) == 0 {
+		return nil
 	}
-	return nil
+	return p.cache.PutBody(r.ISSN, body, r.FetchedAt)
 }
 
-// probe fetches one ISSN with retries. Cache hits are returned without
-// network access. Network errors and 429/5xx trigger exponential
-// backoff (honouring Retry-After when present). 404 is a terminal,
-// valid "not registered" response.
-func (p *Prober) probe(ctx context.Context, issn string) (*Result, error) {
-	if r, ok := p.readCache(issn); ok {
-		return r, nil
-	}
+// fetch requests one ISSN from the portal, waiting for the shared limiter
+// before each attempt. Network errors trigger exponential backoff, 429/5xx
+// throttle the limiter for everyone (honouring Retry-After when present).
+// 404 is a terminal, valid "not registered" response. If all attempts are
+// throttled, an error is returned and nothing is cached.
+//
+// If prev is a cached 200 result, the request is conditional on its ETag
+// and Last-Modified; 304 Not Modified keeps the cached result and only
+// updates the time of the check. The returned flag is true, if the
+// response is unchanged, by 304 or by the body hash.
+func (p *Prober) fetch(ctx context.Context, issn string, prev *probecache.Result) (*probecache.Result, bool, error) {
 	url := fmt.Sprintf(lookupURLFmt, issn)
 	backoff := p.minBackoff
+	if prev != nil && prev.Status != http.StatusOK {
+		prev = nil
+	}
 	var (
-		status int
-		body   []byte
-		lastErr error
+		status             int
+		body               []byte
+		etag, lastModified string
+		lastErr            error
 	)
 	for attempt := 0; attempt <= p.maxRetries; attempt++ {
+		if err := p.limiter.wait(ctx); err != nil {
+			return nil, false, err
+		}
 		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		req.Header.Set("Accept", jsonLDType)
 		req.Header.Set("User-Agent", p.ua)
+		if prev != nil && prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev != nil && prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
 		resp, err := p.client.Do(req)
 		if err != nil {
 			lastErr = err
 			if ctx.Err() != nil {
-				return nil, ctx.Err()
+				return nil, false, ctx.Err()
 			}
 			if err := sleepCtx(ctx, backoff); err != nil {
-				return nil, err
+				return nil, false, err
 			}
 			backoff = nextBackoff(backoff, p.maxBackoff)
 			continue
@@ -280,32 +264,59 @@ func (p *Prober) probe(ctx context.Context, issn string) (*Result, error) {
 				}
 			}
 			resp.Body.Close()
-			if err := sleepCtx(ctx, wait); err != nil {
-				return nil, err
-			}
+			p.limiter.throttle(wait)
 			backoff = nextBackoff(backoff, p.maxBackoff)
 			lastErr = fmt.Errorf("http %d", status)
 			continue
 		}
 		body, _ = io.ReadAll(resp.Body)
 		resp.Body.Close()
+		etag, lastModified = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
 		lastErr = nil
+		p.limiter.healthy()
+		if status == http.StatusOK {
+			var kind sniff.Kind
+			if body, kind, err = sniff.Normalize(body); err != nil {
+				log.Printf("%s: cannot convert %s response: %v", issn, kind, err)
+			}
+			p.responses.Add(kind)
+		}
 		break
 	}
+	if lastErr != nil && status != 0 {
+		return nil, false, fmt.Errorf("giving up after %d attempts: %w", p.maxRetries+1, lastErr)
+	}
+	now := time.Now().UTC()
+	if status == http.StatusNotModified && prev != nil {
+		r := *prev
+		r.CheckedAt = now
+		_ = p.cache.PutResult(&r)
+		return &r, true, nil
+	}
 	reg, leg := classify(status, body)
-	r := &Result{
+	r := &probecache.Result{
 		ISSN:          issn,
 		Status:        status,
 		Registered:    reg,
 		Legacy:        leg,
 		SchemaVersion: schemaVersion,
-		FetchedAt:     time.Now().UTC(),
+		FetchedAt:     now,
+		CheckedAt:     now,
+		Run:           p.runStarted,
+		Seed:          p.seed,
 	}
-	if lastErr != nil && status == 0 {
+	if status == http.StatusOK {
+		r.ETag, r.LastModified = etag, lastModified
+		if len(body) > 0 {
+			r.BodyHash = probecache.BodyHash(body)
+		}
+	}
+	if lastErr != nil {
 		r.Error = lastErr.Error()
 	}
 	_ = p.writeCache(r, body)
-	return r, nil
+	unchanged := prev != nil && r.BodyHash != "" && r.BodyHash == prev.BodyHash
+	return r, unchanged, nil
 }
 
 func nextBackoff(cur, max time.Duration) time.Duration {
@@ -333,49 +344,69 @@ func sleepCtx(ctx context.Context, d time.Duration) error {
 // ----- candidate generation ----------------------------------------------
 
 type candidateSpec struct {
-	mode       string
-	prefixMin  int
-	prefixMax  int
-	sparseMax  int
-	sampleN    int
-	seed       int64
-	stopAfter  int // frontier: stop per block after this many consecutive misses (0 = no stop)
+	mode      string
+	prefixMin int
+	prefixMax int
+	sparseMax int
+	sampleN   int
+	seed      int64
+	offset    int // estimate: sample positions already taken in earlier runs
+	stopAfter int // frontier: stop per block after this many consecutive misses (0 = no stop)
+	// frontier: prior snapshots, oldest first, and the date of the known set
+	history   []snapshot
+	knownDate time.Time
+	now       time.Time
 }
 
-func buildCandidates(known map[string]struct{}, density map[string]int, spec candidateSpec) ([]string, error) {
+func buildCandidates(known *bitset.Set, density map[string]int, spec candidateSpec) ([]string, error) {
 	switch spec.mode {
 	case "estimate":
 		return estimatePool(known, spec), nil
 	case "sparse":
 		return sparseCandidates(known, density, spec), nil
 	case "frontier":
-		return frontierCandidates(known, density, spec), nil
+		return frontierCandidates(known, spec), nil
+	case "recheck":
+		return recheckCandidates(known, spec), nil
 	default:
 		return nil, fmt.Errorf("unknown mode: %s", spec.mode)
 	}
 }
 
-func estimatePool(known map[string]struct{}, spec candidateSpec) []string {
+// recheckCandidates returns the known ISSN in [prefixMin..prefixMax], in
+// ascending order, to verify their registration again.
+func recheckCandidates(known *bitset.Set, spec candidateSpec) []string {
+	var out []string
+	known.Each(func(v issn.ISSN) bool {
+		if p := v.Block(); p >= spec.prefixMin && p <= spec.prefixMax {
+			out = append(out, v.String())
+		}
+		return true
+	})
+	return out
+}
+
+func estimatePool(known *bitset.Set, spec candidateSpec) []string {
 	rng := rand.New(rand.NewSource(spec.seed))
 	pool := make([]string, 0, 1_000_000)
 	for p := spec.prefixMin; p <= spec.prefixMax; p++ {
 		prefix4 := fmt.Sprintf("%04d", p)
 		for _, c := range blockCandidates(prefix4) {
-			if _, ok := known[c]; !ok {
+			if !known.ContainsString(c) {
 				pool = append(pool, c)
 			}
 		}
 	}
 	log.Printf("unknown pool over [%04d..%04d]: %d", spec.prefixMin, spec.prefixMax, len(pool))
 	rng.Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
-	n := spec.sampleN
+	n := spec.offset + spec.sampleN
 	if n > len(pool) {
 		n = len(pool)
 	}
 	return pool[:n]
 }
 
-func sparseCandidates(known map[string]struct{}, density map[string]int, spec candidateSpec) []string {
+func sparseCandidates(known *bitset.Set, density map[string]int, spec candidateSpec) []string {
 	out := make([]string, 0, 50_000)
 	for p := spec.prefixMin; p <= spec.prefixMax; p++ {
 		prefix4 := fmt.Sprintf("%04d", p)
@@ -383,7 +414,7 @@ func sparseCandidates(known map[string]struct{}, density map[string]int, spec ca
 			continue
 		}
 		for _, c := range blockCandidates(prefix4) {
-			if _, ok := known[c]; !ok {
+			if !known.ContainsString(c) {
 				out = append(out, c)
 			}
 		}
@@ -391,38 +422,6 @@ func sparseCandidates(known map[string]struct{}, density map[string]int, spec ca
 	return out
 }
 
-func frontierCandidates(known map[string]struct{}, density map[string]int, spec candidateSpec) []string {
-	out := make([]string, 0, 20_000)
-	for p := spec.prefixMin; p <= spec.prefixMax; p++ {
-		prefix4 := fmt.Sprintf("%04d", p)
-		d := density[prefix4]
-		if d == 0 {
-			// allocate the first few candidates of a fresh block
-			cc := blockCandidates(prefix4)
-			if len(cc) > 32 {
-				cc = cc[:32]
-			}
-			out = append(out, cc...)
-			continue
-		}
-		if d >= 990 {
-			continue
-		}
-		cc := blockCandidates(prefix4)
-		// next-after-max in known; then everything after.
-		maxIdx := -1
-		for i, c := range cc {
-			if _, ok := known[c]; ok {
-				maxIdx = i
-			}
-		}
-		for i := maxIdx + 1; i < len(cc); i++ {
-			out = append(out, cc[i])
-		}
-	}
-	return out
-}
-
 // ----- stats --------------------------------------------------------------
 
 type estimate struct {
@@ -437,6 +436,11 @@ type estimate struct {
 	WilsonLo  float64 `json:"wilson_ci_lo"`
 	WilsonHi  float64 `json:"wilson_ci_hi"`
 	MarginAbs float64 `json:"normal_margin_abs"` // half-width on N̂
+	// WilsonMargin is the half-width of the Wilson interval on N̂.
+	WilsonMargin float64 `json:"wilson_margin_abs"`
+	// StopReason tells why the run ended: target, sample, limit,
+	// exhausted (no more unknown ISSN to sample) or interrupted.
+	StopReason string `json:"stop_reason,omitempty"`
 	// Separate estimate for "legacy" (ack'd but no bibliographic data).
 	LegacyPHat float64 `json:"legacy_p_hat"`
 	LegacyNHat float64 `json:"legacy_n_hat"`
@@ -462,34 +466,86 @@ func computeEstimate(hits, legacy, probes, pool int) estimate {
 	half := z * math.Sqrt(p*(1-p)/n+z*z/(4*n*n)) / denom
 	e.WilsonLo = math.Max(0, centre-half) * float64(pool)
 	e.WilsonHi = math.Min(1, centre+half) * float64(pool)
+	e.WilsonMargin = (e.WilsonHi - e.WilsonLo) / 2
 	lp := float64(legacy) / float64(probes)
 	e.LegacyPHat = lp
 	e.LegacyNHat = lp * float64(pool)
 	return e
 }
 
+// stopRule ends an estimate run early, as soon as the Wilson interval is
+// tight enough.
+type stopRule struct {
+	margin    float64 // half-width on N̂, absolute
+	marginRel float64 // half-width on p, relative to p̂
+	minProbes int
+}
+
+func (r stopRule) enabled() bool {
+	return r.margin > 0 || r.marginRel > 0
+}
+
+// met reports whether all configured targets are reached.
+func (r stopRule) met(e estimate) bool {
+	if !r.enabled() || e.Probes < r.minProbes {
+		return false
+	}
+	if r.margin > 0 && e.WilsonMargin > r.margin {
+		return false
+	}
+	if r.marginRel > 0 {
+		if e.PHat == 0 || e.WilsonMargin/e.NHat > r.marginRel {
+			return false
+		}
+	}
+	return true
+}
+
 // ----- main ---------------------------------------------------------------
 
+// isFlagSet reports whether a flag has been given on the command line.
+func isFlagSet(name string) (found bool) {
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
 func main() {
 	var (
 		issnPath    = flag.String("f", "issn.tsv", "path to known ISSN list (one per line)")
-		cacheDir    = flag.String("d", "", "cache dir (default XDG_CACHE_HOME/issnprobe)")
-		mode        = flag.String("mode", "estimate", "candidate mode: estimate | sparse | frontier | reclassify")
-		delayMs     = flag.Int("delay", 3000, "minimum ms between network requests")
+		cacheDir    = flag.String("d", "", "cache dir or database file ending in .db (default XDG_CACHE_HOME/issnprobe)")
+		mode        = flag.String("mode", "estimate", "candidate mode: estimate | stratified | sparse | frontier | recheck | reclassify | report | merge | export-cache")
+		delayMs     = flag.Int("delay", 3000, "minimum ms between network requests, across all workers")
+		workers     = flag.Int("workers", 1, "number of concurrent probes")
+		burst       = flag.Int("burst", 1, "number of requests that may be sent back to back after idling")
 		saveBody    = flag.Bool("save-body", true, "save JSON-LD body for registered ISSN")
+		useStore    = flag.Bool("store", false, "save bodies in the record store "+probecache.StoreFile+" in the cache dir, instead of one .jsonld file per ISSN")
 		prefixMin   = flag.String("prefix-min", "0000", "4-digit min prefix, inclusive")
 		prefixMax   = flag.String("prefix-max", "3199", "4-digit max prefix, inclusive")
-		sampleN     = flag.Int("n", 400, "sample size (estimate mode)")
+		sampleN     = flag.Int("n", 400, "sample size (estimate mode), total probe budget (stratified mode)")
+		strataCuts  = flag.String("strata", "100,900", "block density cut-points (stratified and report mode)")
+		pilotN      = flag.Int("pilot", 30, "pilot probes per stratum (stratified mode)")
 		sparseMax   = flag.Int("sparse-max", 200, "max block density to count as sparse")
+		maxAge      = flag.Duration("max-age", 0, "check cached results older than this again, with a conditional request (0 = cached results never expire)")
 		limit       = flag.Int("limit", 500, "hard cap on probes per run (0 = no cap)")
-		seed        = flag.Int64("seed", time.Now().UnixNano(), "RNG seed (estimate mode)")
+		margin      = flag.Float64("margin", 0, "stop estimate once the Wilson half-width on N̂ is at most this (sample up to -limit)")
+		marginRel   = flag.Float64("margin-rel", 0, "stop estimate once the Wilson half-width on p is at most this fraction of p̂ (sample up to -limit)")
+		minProbes   = flag.Int("min-probes", 30, "never stop an estimate before this many probes")
+		seed        = flag.Int64("seed", time.Now().UnixNano(), "RNG seed (estimate and stratified mode)")
 		ua          = flag.String("ua", defaultUA, "User-Agent header")
 		timeoutSec  = flag.Int("timeout", 20, "per-request timeout seconds")
 		minBackoff  = flag.Int("backoff-min", 2, "initial backoff seconds on 429/5xx")
 		maxBackoff  = flag.Int("backoff-max", 60, "max backoff seconds")
 		maxRetries  = flag.Int("retries", 5, "max retries per request")
 		dryRun      = flag.Bool("dry-run", false, "print candidates only, no probing")
-		outPath     = flag.String("o", "", "write JSONL results to file (default stdout)")
+		outPath     = flag.String("o", "", "write JSONL results to file (default stdout); merge mode: new list (default issn-YYYY-MM-DD.tsv); export-cache mode: .db file or directory")
+		history     = flag.String("history", "", "frontier mode: prior snapshots of the known list, comma separated, dated by filename (YYYY-MM-DD) or mtime")
+		stopAfter   = flag.Int("stop-after", 20, "frontier mode: give up a block after this many consecutive misses (0 = never)")
+		since       = flag.String("since", "", "merge mode: only promote hits fetched on or after this date (YYYY-MM-DD)")
+		resume      = flag.String("resume", "", "continue the estimate sample recorded in this manifest file (created if missing); with -mode merge, seed for runs without one")
 		showVersion = flag.Bool("version", false, "print version and exit")
 	)
 	flag.Parse()
@@ -501,14 +557,49 @@ func main() {
 	if *cacheDir == "" {
 		*cacheDir = filepath.Join(xdg.CacheHome, "issnprobe")
 	}
-	if err := os.MkdirAll(*cacheDir, 0o755); err != nil {
+	cache, err := probecache.Open(*cacheDir)
+	if err != nil {
 		log.Fatal(err)
 	}
+	defer func() {
+		if err := cache.Close(); err != nil {
+			log.Printf("cache: %v", err)
+		}
+	}()
+	if *useStore {
+		d, ok := cache.(*probecache.Dir)
+		if !ok {
+			log.Fatal("-store only applies to a cache directory")
+		}
+		if d.Store, err = store.Open(probecache.StorePath(d.Path)); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Copy the cache into a database or a directory. No network.
+	if *mode == "export-cache" {
+		if *outPath == "" {
+			log.Fatal("export-cache requires -o, a .db file or a directory")
+		}
+		dst, err := probecache.Open(*outPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		n, err := probecache.Copy(dst, cache)
+		if cerr := dst.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("export-cache: copied %d results from %s to %s", n, cache, dst)
+		return
+	}
 
 	// Offline maintenance: walk the cache, re-run classify() on saved
 	// bodies, rewrite results. No network.
 	if *mode == "reclassify" {
-		scanned, changed, err := reclassifyCache(*cacheDir)
+		scanned, changed, err := reclassifyCache(cache)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -516,6 +607,11 @@ func main() {
 		return
 	}
 
+	// Rechecking only makes sense, if cached results expire.
+	if *mode == "recheck" && *maxAge <= 0 {
+		log.Fatal("recheck requires -max-age")
+	}
+
 	pMin, err := strconv.Atoi(*prefixMin)
 	if err != nil || pMin < 0 || pMin > 9999 {
 		log.Fatalf("bad -prefix-min: %q", *prefixMin)
@@ -530,15 +626,124 @@ func main() {
 	if err != nil {
 		log.Fatalf("load %s: %v", *issnPath, err)
 	}
-	log.Printf("loaded %d known ISSN", len(known))
+	log.Printf("loaded %d known ISSN", known.Len())
+
+	// Promote registered cache entries into a new list. No network.
+	if *mode == "merge" {
+		var t time.Time
+		if *since != "" {
+			if t, err = time.Parse("2006-01-02", *since); err != nil {
+				log.Fatalf("bad -since: %v", err)
+			}
+		}
+		hits, err := cacheHits(cache, known, t)
+		if err != nil {
+			log.Fatal(err)
+		}
+		filename := *outPath
+		if filename == "" {
+			filename = fmt.Sprintf("issn-%s.tsv", time.Now().Format("2006-01-02"))
+		}
+		// Runs before seeds were recorded take it from a manifest.
+		var man *manifest
+		if *resume != "" {
+			if man, err = readManifest(*resume); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err := writeMerged(filename, known, *issnPath, cache, hits, man); err != nil {
+			log.Fatal(err)
+		}
+		if err := writeGrowth(os.Stdout, known, hits); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("wrote %d ISSN to %s (%d added)", known.Len()+len(hits), filename, len(hits))
+		return
+	}
+
+	var man *manifest
+	if *resume != "" {
+		if *mode != "estimate" {
+			log.Fatal("-resume only works with -mode estimate")
+		}
+		if man, err = readManifest(*resume); err != nil {
+			log.Fatal(err)
+		}
+		fp := fingerprint(known)
+		if man == nil {
+			man = &manifest{
+				Seed:      *seed,
+				PrefixMin: pMin,
+				PrefixMax: pMax,
+				PoolSize:  countUnknownPool(known, pMin, pMax),
+				Known:     fp,
+				Created:   time.Now().UTC(),
+			}
+		} else {
+			// Seed and range come from the manifest; a range given on the
+			// command line must match it.
+			if !isFlagSet("prefix-min") {
+				pMin = man.PrefixMin
+			}
+			if !isFlagSet("prefix-max") {
+				pMax = man.PrefixMax
+			}
+			*seed = man.Seed
+			if err := man.check(fp, pMin, pMax, countUnknownPool(known, pMin, pMax)); err != nil {
+				log.Fatalf("cannot resume %s: %v", *resume, err)
+			}
+			log.Printf("resuming sample from %s: seed=%d range=[%04d..%04d] sampled=%d sessions=%d",
+				*resume, man.Seed, pMin, pMax, man.Sampled, man.Sessions)
+		}
+	}
 
 	density := make(map[string]int, 4000)
-	for issn := range known {
-		if len(issn) == 9 {
-			density[issn[:4]]++
+	for p := 0; p <= 9999; p++ {
+		if n := known.BlockCount(p); n > 0 {
+			density[fmt.Sprintf("%04d", p)] = n
 		}
 	}
 
+	// Offline estimate from whatever is in the cache.
+	if *mode == "report" {
+		cuts, err := parseCuts(*strataCuts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rep, err := newCacheReport(cache, known, pMin, pMax)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var out io.Writer = os.Stdout
+		if *outPath != "" {
+			f, err := os.Create(*outPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			out = f
+		}
+		if err := rep.writeTo(out, density, cuts); err != nil {
+			log.Fatal(err)
+		}
+		rep.logSummary()
+		if c, pool := rep.total(); c.probed > 0 {
+			b, _ := json.MarshalIndent(computeEstimate(c.hits, c.legacy, c.probed, pool), "", "  ")
+			fmt.Fprintln(os.Stderr, string(b))
+		}
+		return
+	}
+
+	rule := stopRule{margin: *margin, marginRel: *marginRel, minProbes: *minProbes}
+	stopEarly := *mode == "estimate" && rule.enabled() && *limit > 0
+	if stopEarly {
+		// The sample is only an upper bound, the run stops early.
+		if isFlagSet("n") && *sampleN != *limit {
+			log.Fatalf("-n %d conflicts with -margin or -margin-rel, which sample up to -limit %d", *sampleN, *limit)
+		}
+		log.Printf("sampling up to -limit %d, until the target margin is reached", *limit)
+		*sampleN = *limit
+	}
 	spec := candidateSpec{
 		mode:      *mode,
 		prefixMin: pMin,
@@ -546,23 +751,66 @@ func main() {
 		sparseMax: *sparseMax,
 		sampleN:   *sampleN,
 		seed:      *seed,
+		stopAfter: *stopAfter,
+		now:       time.Now(),
 	}
-	candidates, err := buildCandidates(known, density, spec)
-	if err != nil {
-		log.Fatal(err)
-	}
-	poolSize := len(candidates)
-	if *mode == "estimate" {
-		// For estimate mode, the pool for N̂ is the full unknown pool
-		// in [prefixMin..prefixMax], not just the sampled subset.
-		poolSize = countUnknownPool(known, pMin, pMax)
+	if *mode == "frontier" {
+		if spec.knownDate, err = issn.SnapshotFileDate(*issnPath); err != nil {
+			log.Fatal(err)
+		}
+		if *history != "" {
+			if spec.history, err = loadSnapshots(strings.Split(*history, ",")); err != nil {
+				log.Fatal(err)
+			}
+		}
 	}
-	if *mode != "estimate" {
-		// stable ordering for sparse/frontier so runs are reproducible
-		sort.Strings(candidates)
+	if man != nil {
+		spec.offset = man.Sampled
 	}
-	if *limit > 0 && len(candidates) > *limit {
-		candidates = candidates[:*limit]
+	var (
+		candidates []string
+		poolSize   int
+		strata     *stratification
+		budget     = *sampleN
+		limited    bool // The candidates were cut at -limit.
+	)
+	if *mode == "stratified" {
+		// Only the pilot sample is known upfront, the rest is allocated
+		// after the pilot has been probed.
+		cuts, err := parseCuts(*strataCuts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *limit > 0 && budget > *limit {
+			budget = *limit
+		}
+		strata = newStratification(known, density, pMin, pMax, cuts, *seed)
+		if err := strata.planPilot(*pilotN, budget); err != nil {
+			log.Fatal(err)
+		}
+		candidates = strata.candidates()
+	} else {
+		candidates, err = buildCandidates(known, density, spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		poolSize = len(candidates)
+		if *mode == "estimate" {
+			// For estimate mode, the pool for N̂ is the full unknown pool
+			// in [prefixMin..prefixMax], not just the sampled subset.
+			poolSize = countUnknownPool(known, pMin, pMax)
+		} else if *mode == "sparse" {
+			// stable ordering for sparse so runs are reproducible,
+			// frontier candidates are ranked
+			sort.Strings(candidates)
+		}
+		if *limit > 0 && len(candidates) > spec.offset+*limit {
+			candidates = candidates[:spec.offset+*limit]
+			limited = true
+		}
+		if stopEarly && len(candidates) >= spec.offset+*limit {
+			limited = true
+		}
 	}
 	log.Printf("candidates to probe: %d (mode=%s)", len(candidates), *mode)
 
@@ -573,16 +821,23 @@ func main() {
 		return
 	}
 
-	client := &http.Client{Timeout: time.Duration(*timeoutSec) * time.Second}
+	var (
+		client  = &http.Client{Timeout: time.Duration(*timeoutSec) * time.Second}
+		started = time.Now().UTC()
+	)
 	prober := &Prober{
-		client:     client,
-		cacheDir:   *cacheDir,
-		ua:         *ua,
-		delay:      time.Duration(*delayMs) * time.Millisecond,
+		client: client,
+		cache:  cache,
+		ua:     *ua,
+		limiter: newLimiter(time.Duration(*delayMs)*time.Millisecond,
+			time.Duration(*maxBackoff)*time.Second, *burst),
 		saveBody:   *saveBody,
+		maxAge:     *maxAge,
 		minBackoff: time.Duration(*minBackoff) * time.Second,
 		maxBackoff: time.Duration(*maxBackoff) * time.Second,
 		maxRetries: *maxRetries,
+		runStarted: started,
+		seed:       runSeed(*mode, *seed),
 	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
@@ -602,92 +857,138 @@ func main() {
 	enc := json.NewEncoder(bw)
 
 	var (
-		probes, hits, legacy, cached, errs int
-		lastNet                            time.Time
+		t          = &tally{enc: enc}
+		stats      runStats
+		stopReason string
 	)
-	for _, issn := range candidates {
-		if ctx.Err() != nil {
-			break
-		}
-		_, hadCache := prober.readCache(issn)
-		if !hadCache {
-			// enforce min inter-request delay
-			if !lastNet.IsZero() {
-				elapsed := time.Since(lastNet)
-				if elapsed < prober.delay {
-					if err := sleepCtx(ctx, prober.delay-elapsed); err != nil {
-						break
-					}
+	if strata != nil {
+		stats = strata.run(ctx, prober, candidates, *workers, budget, t)
+	} else {
+		f := t.add
+		if *mode == "estimate" && rule.enabled() {
+			f = func(o outcome) bool {
+				t.add(o)
+				if o.err == nil && rule.met(computeEstimate(t.hits, t.legacy, t.probes, poolSize)) {
+					stopReason = "target"
+					return false
 				}
+				return true
 			}
-			lastNet = time.Now()
-		} else {
-			cached++
 		}
-		r, err := prober.probe(ctx, issn)
-		if err != nil {
-			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-				break
+		if man != nil {
+			// Remember how far into the sequence we got.
+			pos := make(map[string]int, len(candidates))
+			for i, c := range candidates {
+				pos[c] = i
+			}
+			g := f
+			f = func(o outcome) bool {
+				if o.err == nil && pos[o.issn] >= man.Sampled {
+					man.Sampled = pos[o.issn] + 1
+				}
+				return g(o)
 			}
-			errs++
-			log.Printf("probe %s: %v", issn, err)
-			continue
-		}
-		probes++
-		if r.Registered {
-			hits++
-		}
-		if r.Legacy {
-			legacy++
-		}
-		if err := enc.Encode(r); err != nil {
-			log.Printf("encode %s: %v", issn, err)
 		}
+		var (
+			skip func(string) bool
+			ms   *missStopper
+		)
+		if *mode == "frontier" && spec.stopAfter > 0 {
+			ms = newMissStopper(spec.stopAfter)
+			g := f
+			f = func(o outcome) bool {
+				ms.observe(o)
+				return g(o)
+			}
+			skip = ms.skip
+		}
+		stats = prober.run(ctx, candidates, *workers, f, skip)
+		if ms != nil {
+			log.Printf("frontier: gave up %d blocks after %d consecutive misses, skipped %d candidates",
+				ms.stopped(), spec.stopAfter, ms.skipped)
+		}
+	}
+	switch {
+	case stopReason != "":
+		log.Printf("target margin reached after %d probes", t.probes)
+	case ctx.Err() != nil:
+		stopReason = "interrupted"
+	case limited:
+		stopReason = "limit"
+	case stopEarly:
+		// The pool ran out before the target or the limit.
+		stopReason = "exhausted"
+	default:
+		stopReason = "sample"
 	}
 	bw.Flush()
-	log.Printf("probes=%d hits=%d legacy=%d cached=%d errors=%d",
-		probes, hits, legacy, cached, errs)
-	if *mode == "estimate" && probes > 0 {
-		e := computeEstimate(hits, legacy, probes, poolSize)
+	log.Printf("probes=%d hits=%d legacy=%d cached=%d unchanged=%d errors=%d",
+		t.probes, t.hits, t.legacy, t.cached, t.unchanged, t.errs)
+	if stats.requests > 0 {
+		budget := "unlimited"
+		if *delayMs > 0 {
+			budget = fmt.Sprintf("%.2f/s", 1000/float64(*delayMs))
+		}
+		log.Printf("requests=%d rate=%.2f/s (budget %s) workers=%d elapsed=%v",
+			stats.requests, stats.rate(), budget, *workers, stats.elapsed.Round(time.Second))
+	}
+	if n := prober.responses.Anomalies(); n > 0 {
+		log.Printf("anomalous responses=%d (%s)", n, &prober.responses)
+	}
+	run := &probecache.Run{
+		Started:   started,
+		Finished:  time.Now().UTC(),
+		Mode:      *mode,
+		Args:      os.Args[1:],
+		Seed:      runSeed(*mode, *seed),
+		Probes:    t.probes,
+		Hits:      t.hits,
+		Legacy:    t.legacy,
+		Cached:    t.cached,
+		Unchanged: t.unchanged,
+		Errors:    t.errs,
+		Requests:  stats.requests,
+	}
+	if err := cache.AddRun(run); err != nil {
+		log.Printf("cannot record run: %v", err)
+	}
+	if man != nil {
+		man.Sessions++
+		man.Updated = time.Now().UTC()
+		man.Probes, man.Hits, man.Legacy = t.probes, t.hits, t.legacy
+		if err := man.writeFile(*resume); err != nil {
+			log.Printf("cannot write manifest: %v", err)
+		} else {
+			log.Printf("wrote manifest to %s, sampled=%d", *resume, man.Sampled)
+		}
+	}
+	switch {
+	case *mode == "estimate" && t.probes > 0:
+		e := computeEstimate(t.hits, t.legacy, t.probes, poolSize)
+		e.StopReason = stopReason
 		b, _ := json.MarshalIndent(e, "", "  ")
 		fmt.Fprintln(os.Stderr, string(b))
+	case strata != nil && t.probes > 0:
+		b, _ := json.MarshalIndent(strata.estimate(), "", "  ")
+		fmt.Fprintln(os.Stderr, string(b))
 	}
 }
 
 // reclassifyCache walks the cache tree and rewrites every Result JSON
 // by re-running classify() against the saved JSON-LD body (when
 // present). Pure offline operation — no network access.
-func reclassifyCache(dir string) (scanned int, changed int, err error) {
-	err = filepath.Walk(dir, func(path string, info os.FileInfo, werr error) error {
-		if werr != nil {
-			return werr
-		}
-		if info.IsDir() || !strings.HasSuffix(path, ".json") {
-			return nil
-		}
-		b, rerr := os.ReadFile(path)
-		if rerr != nil {
-			return rerr
-		}
-		var r Result
-		if jerr := json.Unmarshal(b, &r); jerr != nil {
-			return nil
-		}
+func reclassifyCache(c probecache.Cache) (scanned int, changed int, err error) {
+	err = c.Walk(func(r *probecache.Result) error {
 		scanned++
-		if r.ISSN == "" || len(r.ISSN) != 9 {
-			return nil
-		}
-		bodyFile := filepath.Join(filepath.Dir(path), r.ISSN+".jsonld")
 		prevReg, prevLeg, prevVer := r.Registered, r.Legacy, r.SchemaVersion
-		if body, berr := os.ReadFile(bodyFile); berr == nil {
+		if body, berr := c.Body(r.ISSN); berr == nil {
 			reg, leg := classify(r.Status, body)
 			r.Registered = reg
 			r.Legacy = leg
 		}
 		r.SchemaVersion = schemaVersion
 		if r.Registered != prevReg || r.Legacy != prevLeg || r.SchemaVersion != prevVer {
-			nb, _ := json.Marshal(&r)
-			if werr := os.WriteFile(path, nb, 0o644); werr != nil {
+			if werr := c.PutResult(r); werr != nil {
 				return werr
 			}
 			changed++
@@ -697,15 +998,6 @@ func reclassifyCache(dir string) (scanned int, changed int, err error) {
 	return scanned, changed, err
 }
 
-func countUnknownPool(known map[string]struct{}, pMin, pMax int) int {
-	count := 0
-	for p := pMin; p <= pMax; p++ {
-		prefix4 := fmt.Sprintf("%04d", p)
-		for _, c := range blockCandidates(prefix4) {
-			if _, ok := known[c]; !ok {
-				count++
-			}
-		}
-	}
-	return count
+func countUnknownPool(known *bitset.Set, pMin, pMax int) int {
+	return (pMax-pMin+1)*1000 - known.Count(pMin*1000, (pMax+1)*1000)
 }
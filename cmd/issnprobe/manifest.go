@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/miku/issnlister/atomic"
+	"github.com/miku/issnlister/bitset"
+)
+
+// manifest pins down the random sample of an estimate run, so that it
+// can be continued in a later session. The sample is the shuffled unknown
+// pool, which only depends on the seed, the prefix range and the known
+// set; earlier positions of the sequence are read back from the cache,
+// so resumed runs merge into one estimate.
+type manifest struct {
+	Seed      int64     `json:"seed"`
+	PrefixMin int       `json:"prefix_min"`
+	PrefixMax int       `json:"prefix_max"`
+	PoolSize  int       `json:"pool_size"`
+	Known     string    `json:"known"` // fingerprint of the known set
+	Sampled   int       `json:"sampled"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated,omitempty"`
+	Sessions  int       `json:"sessions"`
+	// Summary of the last session, which includes earlier ones.
+	Probes int `json:"probes"`
+	Hits   int `json:"hits"`
+	Legacy int `json:"legacy"`
+}
+
+// fingerprint identifies a known set by the hash of its serialization.
+func fingerprint(known *bitset.Set) string {
+	h := sha256.New()
+	if _, err := known.WriteTo(h); err != nil {
+		panic(err) // hash writes do not fail
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// readManifest reads a manifest, a missing file is not an error.
+func readManifest(filename string) (*manifest, error) {
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return &m, nil
+}
+
+// check verifies that a run would draw the same sequence.
+func (m *manifest) check(known string, pMin, pMax, poolSize int) error {
+	switch {
+	case m.Known != known:
+		return fmt.Errorf("known set changed: manifest has %s, got %s", m.Known, known)
+	case m.PrefixMin != pMin || m.PrefixMax != pMax:
+		return fmt.Errorf("prefix range changed: manifest has [%04d..%04d]", m.PrefixMin, m.PrefixMax)
+	case m.PoolSize != poolSize:
+		return fmt.Errorf("pool size changed: manifest has %d, got %d", m.PoolSize, poolSize)
+	}
+	return nil
+}
+
+func (m *manifest) writeFile(filename string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomic.WriteFile(filename, append(b, '\n'), 0o644)
+}
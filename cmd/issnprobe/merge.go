@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/miku/issnlister/atomic"
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/probecache"
+)
+
+// promoted is a registered ISSN found in the cache, not in the snapshot,
+// with the start time and seed of the run, that fetched it, if recorded.
+type promoted struct {
+	issn      issn.ISSN
+	fetchedAt time.Time
+	run       time.Time
+	seed      int64
+}
+
+// cacheHits returns registered cache entries that are not known yet,
+// fetched at or after since (if not zero), in ISSN order.
+func cacheHits(c probecache.Cache, known *bitset.Set, since time.Time) ([]promoted, error) {
+	var (
+		hits  = bitset.New()
+		found = make(map[issn.ISSN]promoted)
+		stale int
+	)
+	err := c.Walk(func(r *probecache.Result) error {
+		v, err := issn.Parse(r.ISSN)
+		if err != nil || known.Contains(v) {
+			return nil
+		}
+		if !since.IsZero() && r.FetchedAt.Before(since) {
+			return nil
+		}
+		if !upgrade(c, r) {
+			stale++
+			return nil
+		}
+		if r.Registered {
+			hits.Add(v)
+			found[v] = promoted{issn: v, fetchedAt: r.FetchedAt, run: r.Run, seed: r.Seed}
+		}
+		return nil
+	})
+	if stale > 0 {
+		log.Printf("skipped %d results classified by an older schema without saved body", stale)
+	}
+	var result []promoted
+	hits.Each(func(v issn.ISSN) bool {
+		result = append(result, found[v])
+		return true
+	})
+	return result, err
+}
+
+// writeMerged writes the known set plus hits as a sorted list and a
+// provenance sidecar with one line per added ISSN: ISSN, source, fetch time
+// and the start time and seed of the run, that fetched it, if known. The
+// start time identifies a run in any cache, also one copied to another
+// backend or interrupted before it was logged. For results cached without
+// a run, the run is looked up in the run log by the fetch time. The
+// snapshot itself is named in a comment. Runs without a seed take it from
+// man, if they fall into the time span of the manifest.
+func writeMerged(filename string, known *bitset.Set, snapshot string, cache probecache.Cache, hits []promoted, man *manifest) error {
+	merged := known.Clone()
+	for _, h := range hits {
+		merged.Add(h.issn)
+	}
+	var buf bytes.Buffer
+	if err := merged.WriteList(&buf); err != nil {
+		return err
+	}
+	if err := atomic.WriteFile(filename, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	runs, err := cache.Runs()
+	if err != nil {
+		return err
+	}
+	buf.Reset()
+	fmt.Fprintf(&buf, "# %s: %d ISSN from snapshot %s\n", filename, known.Len(), snapshot)
+	fmt.Fprintf(&buf, "# issn\tsource\tfetched\trun\tseed\n")
+	for _, h := range hits {
+		started, seed := h.run, h.seed
+		if started.IsZero() {
+			if r := coveringRun(runs, h.fetchedAt); r != nil {
+				started, seed = r.Started, r.Seed
+			}
+		}
+		var run, s string
+		if !started.IsZero() {
+			run = started.Format(time.RFC3339)
+		}
+		switch {
+		case seed != 0:
+			s = strconv.FormatInt(seed, 10)
+		case run != "" && man != nil && !started.Before(man.Created) && !started.After(man.Updated):
+			s = strconv.FormatInt(man.Seed, 10)
+		}
+		fmt.Fprintf(&buf, "%s\tprobecache:%s\t%s\t%s\t%s\n", h.issn, cache, h.fetchedAt.Format(time.RFC3339), run, s)
+	}
+	return atomic.WriteFile(filename+".provenance.tsv", buf.Bytes(), 0o644)
+}
+
+// coveringRun returns the latest run, that started before t and finished
+// after it, or nil.
+func coveringRun(runs []probecache.Run, t time.Time) *probecache.Run {
+	var found *probecache.Run
+	for i, r := range runs {
+		if r.Started.After(t) || r.Finished.Before(t) {
+			continue
+		}
+		if found == nil || r.Started.After(found.Started) {
+			found = &runs[i]
+		}
+	}
+	return found
+}
+
+// runSeed returns the seed to record for a run, for modes drawing random
+// samples.
+func runSeed(mode string, seed int64) int64 {
+	switch mode {
+	case "estimate", "stratified":
+		return seed
+	}
+	return 0
+}
+
+// writeGrowth prints added ISSN per two-digit prefix, for prefixes with
+// additions.
+func writeGrowth(w io.Writer, known *bitset.Set, hits []promoted) error {
+	added := make(map[int]int)
+	for _, h := range hits {
+		added[h.issn.Block()/100]++
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "prefix\tknown\tadded\tgrowth\t")
+	for p := 0; p < 100; p++ {
+		if added[p] == 0 {
+			continue
+		}
+		n := known.Count(p*100_000, (p+1)*100_000)
+		fmt.Fprintf(tw, "%02d\t%d\t%d\t%s\t\n", p, n, added[p], growth(n, added[p]))
+	}
+	fmt.Fprintf(tw, "total\t%d\t%d\t%s\t\n", known.Len(), len(hits), growth(known.Len(), len(hits)))
+	return tw.Flush()
+}
+
+func growth(base, added int) string {
+	if base == 0 {
+		return "new"
+	}
+	return fmt.Sprintf("+%.3f%%", 100*float64(added)/float64(base))
+}
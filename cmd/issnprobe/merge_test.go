@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/probecache"
+)
+
+func TestWriteMergedProvenance(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := probecache.Open(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		t0  = time.Date(2026, 4, 19, 10, 0, 0, 0, time.UTC)
+		old = &probecache.Run{Started: t0, Finished: t0.Add(time.Hour), Mode: "estimate"}
+		now = &probecache.Run{Started: t0.Add(2 * time.Hour), Finished: t0.Add(3 * time.Hour), Mode: "stratified", Seed: 42}
+		man = &manifest{Seed: 7, Created: t0, Updated: t0.Add(time.Hour)}
+	)
+	for _, r := range []*probecache.Run{old, now} {
+		if err := cache.AddRun(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hits := []promoted{
+		{issn: issn.MustParse("0000-0019"), fetchedAt: t0.Add(30 * time.Minute)},
+		{issn: issn.MustParse("1932-6203"), fetchedAt: t0.Add(150 * time.Minute)},
+		{issn: issn.MustParse("2257-6754"), fetchedAt: t0.Add(5 * time.Hour)},
+		// Fetched by an interrupted run, which was not logged.
+		{issn: issn.MustParse("2434-561X"), fetchedAt: t0.Add(7 * time.Hour), run: t0.Add(6 * time.Hour), seed: 99},
+	}
+	filename := filepath.Join(dir, "issn.tsv")
+	if err := writeMerged(filename, bitset.New(), "issn-old.tsv", cache, hits, man); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filename + ".provenance.tsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][]string
+	for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			got = append(got, strings.Split(line, "\t"))
+		}
+	}
+	want := [][2]string{
+		{"2026-04-19T10:00:00Z", "7"},
+		{"2026-04-19T12:00:00Z", "42"},
+		{"", ""},
+		{"2026-04-19T16:00:00Z", "99"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d: %s", len(got), len(want), b)
+	}
+	for i, w := range want {
+		if got[i][3] != w[0] || got[i][4] != w[1] {
+			t.Errorf("%s: got run %q seed %q, want %q %q", got[i][0], got[i][3], got[i][4], w[0], w[1])
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/miku/issnlister/probecache"
+)
+
+// outcome is the result of probing a single candidate.
+type outcome struct {
+	issn   string
+	result *probecache.Result
+	cached bool
+	// unchanged is set for an expired cached result, that the portal
+	// confirmed as not modified.
+	unchanged bool
+	err       error
+}
+
+// tally counts outcomes and writes results as JSON lines.
+type tally struct {
+	enc                                *json.Encoder
+	probes, hits, legacy, cached, errs int
+	unchanged                          int
+}
+
+// add records an outcome, it is suitable as a run callback.
+func (t *tally) add(o outcome) bool {
+	if o.err != nil {
+		t.errs++
+		log.Printf("probe %s: %v", o.issn, o.err)
+		return true
+	}
+	if o.cached {
+		t.cached++
+	}
+	if o.unchanged {
+		t.unchanged++
+	}
+	t.probes++
+	if o.result.Registered {
+		t.hits++
+	}
+	if o.result.Legacy {
+		t.legacy++
+	}
+	if err := t.enc.Encode(o.result); err != nil {
+		log.Printf("encode %s: %v", o.issn, err)
+	}
+	return true
+}
+
+// runStats summarizes a probe run.
+type runStats struct {
+	started  time.Time
+	elapsed  time.Duration
+	requests int // network requests, including retries
+}
+
+// rate returns the achieved network requests per second.
+func (s runStats) rate() float64 {
+	if s.elapsed <= 0 {
+		return 0
+	}
+	return float64(s.requests) / s.elapsed.Seconds()
+}
+
+// run probes candidates with a pool of workers, which share the limiter.
+// Cache hits do not touch the limiter, expired cache entries are checked
+// with a conditional request. Outcomes are passed to f in
+// completion order, from a single goroutine; if f returns false, no more
+// candidates are started and in-flight probes are cancelled. If skip is
+// not nil, candidates for which it returns true are left out.
+func (p *Prober) run(ctx context.Context, candidates []string, workers int, f func(o outcome) bool, skip func(issn string) bool) runStats {
+	if workers < 1 {
+		workers = 1
+	}
+	var (
+		stats          = runStats{started: time.Now()}
+		reqs, _, _     = p.limiter.stats()
+		queue          = make(chan string)
+		out            = make(chan outcome)
+		wg             sync.WaitGroup
+		runCtx, cancel = context.WithCancel(ctx)
+	)
+	defer cancel()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for issn := range queue {
+				prev, ok := p.readCache(issn)
+				if ok && !p.expired(prev) {
+					out <- outcome{issn: issn, result: prev, cached: true}
+					continue
+				}
+				r, unchanged, err := p.fetch(runCtx, issn, prev)
+				out <- outcome{issn: issn, result: r, unchanged: unchanged, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(queue)
+		for _, c := range candidates {
+			if skip != nil && skip(c) {
+				continue
+			}
+			select {
+			case <-runCtx.Done():
+				return
+			case queue <- c:
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	stopped := false
+	for o := range out {
+		if stopped {
+			continue
+		}
+		if o.err != nil && (errors.Is(o.err, context.Canceled) || errors.Is(o.err, context.DeadlineExceeded)) {
+			continue
+		}
+		if !f(o) {
+			stopped = true
+			cancel()
+		}
+	}
+	stats.elapsed = time.Since(stats.started)
+	n, throttled, interval := p.limiter.stats()
+	stats.requests = n - reqs
+	if throttled > 0 {
+		log.Printf("throttled %d times, current interval %v", throttled, interval)
+	}
+	return stats
+}
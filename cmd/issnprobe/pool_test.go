@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miku/issnlister/probecache"
+)
+
+// portal answers lookups without network access, registered ISSN get a
+// JSON-LD record, all others a 404.
+type portal struct {
+	mu         sync.Mutex
+	registered map[string]bool
+	requests   []string
+}
+
+func (p *portal) RoundTrip(req *http.Request) (*http.Response, error) {
+	v := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+	p.mu.Lock()
+	p.requests = append(p.requests, v)
+	p.mu.Unlock()
+	resp := &http.Response{
+		StatusCode: http.StatusNotFound,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}
+	if p.registered[v] {
+		resp.StatusCode = http.StatusOK
+		resp.Body = io.NopCloser(strings.NewReader(`{"@graph": [{"@id": "resource/ISSN/` + v + `", "mainTitle": "T"}]}`))
+	}
+	return resp, nil
+}
+
+func TestProberRun(t *testing.T) {
+	var (
+		started = time.Now()
+		pt      = &portal{registered: map[string]bool{"0378-5955": true, "1932-6203": true}}
+		cache   = &probecache.Dir{Path: t.TempDir()}
+		p       = &Prober{
+			client:     &http.Client{Transport: pt},
+			cache:      cache,
+			limiter:    newLimiter(time.Millisecond, time.Second, 1),
+			runStarted: started,
+			seed:       7,
+		}
+	)
+	// A cached result is not fetched again.
+	cached := &probecache.Result{ISSN: "0000-0019", Status: http.StatusNotFound, SchemaVersion: schemaVersion, FetchedAt: started}
+	if err := cache.PutResult(cached); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		candidates = []string{"0000-0019", "0000-0027", "0378-5955", "1932-6203", "2434-561X"}
+		outcomes   = make(map[string]outcome)
+	)
+	stats := p.run(context.Background(), candidates, 3, func(o outcome) bool {
+		outcomes[o.issn] = o
+		return true
+	}, func(v string) bool { return v == "2434-561X" })
+	if len(outcomes) != 4 {
+		t.Fatalf("got %d outcomes, want 4", len(outcomes))
+	}
+	sort.Strings(pt.requests)
+	if got := strings.Join(pt.requests, " "); got != "0000-0027 0378-5955 1932-6203" {
+		t.Errorf("requests: got %s", got)
+	}
+	if stats.requests != 3 {
+		t.Errorf("got %d requests in stats, want 3", stats.requests)
+	}
+	if o := outcomes["0000-0019"]; !o.cached || o.result.Registered {
+		t.Errorf("cached outcome: got %+v", o)
+	}
+	for v, want := range map[string]bool{"0000-0027": false, "0378-5955": true, "1932-6203": true} {
+		o := outcomes[v]
+		if o.err != nil || o.cached || o.result.Registered != want {
+			t.Errorf("%s: got %+v, %v, want registered %v", v, o, o.err, want)
+			continue
+		}
+		if !o.result.Run.Equal(started) || o.result.Seed != 7 {
+			t.Errorf("%s: got run %v, seed %d", v, o.result.Run, o.result.Seed)
+		}
+		if _, err := cache.Result(v); err != nil {
+			t.Errorf("%s: not cached: %v", v, err)
+		}
+	}
+
+	// Stop after the first outcome.
+	var n int
+	p.run(context.Background(), []string{"0000-0035", "0000-0043", "0000-0051"}, 1, func(o outcome) bool {
+		n++
+		return false
+	}, nil)
+	if n != 1 {
+		t.Errorf("got %d outcomes after stop, want 1", n)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/probecache"
+)
+
+// blockCount counts cached probe results within a block.
+type blockCount struct {
+	probed, hits, legacy int
+	tail                 int // probes after the highest known ISSN of the block
+}
+
+func (c *blockCount) add(o *blockCount) {
+	c.probed += o.probed
+	c.hits += o.hits
+	c.legacy += o.legacy
+	c.tail += o.tail
+}
+
+func (c *blockCount) rate() float64 {
+	if c.probed == 0 {
+		return 0
+	}
+	return float64(c.hits) / float64(c.probed)
+}
+
+// cacheReport summarizes the cache over a prefix range, without any
+// network access.
+type cacheReport struct {
+	pMin, pMax int
+	blocks     map[int]*blockCount
+	unknown    map[int]int // unknown pool size per block
+	tailPool   map[int]int // unknown ISSN after the highest known per block
+	scanned    int
+	outside    int // outside of the prefix range
+	known      int // in the known set by now
+	errors     int // no valid response cached
+	stale      int // classified with an older schema, without saved body
+}
+
+// newCacheReport walks the cache and counts results per block. Entries
+// from an older classifier are re-classified in memory, if the body is
+// available.
+func newCacheReport(cache probecache.Cache, known *bitset.Set, pMin, pMax int) (*cacheReport, error) {
+	rep := &cacheReport{
+		pMin:     pMin,
+		pMax:     pMax,
+		blocks:   make(map[int]*blockCount),
+		unknown:  make(map[int]int),
+		tailPool: make(map[int]int),
+	}
+	for p := pMin; p <= pMax; p++ {
+		cc := blockCandidates(fmt.Sprintf("%04d", p))
+		last := -1
+		for i, c := range cc {
+			if known.ContainsString(c) {
+				last = i
+			}
+		}
+		for i, c := range cc {
+			if !known.ContainsString(c) {
+				rep.unknown[p]++
+				if i > last {
+					rep.tailPool[p]++
+				}
+			}
+		}
+	}
+	err := cache.Walk(func(r *probecache.Result) error {
+		rep.scanned++
+		v, err := issn.Parse(r.ISSN)
+		if err != nil {
+			return nil
+		}
+		p := v.Block()
+		switch {
+		case p < pMin || p > pMax:
+			rep.outside++
+			return nil
+		case known.Contains(v):
+			rep.known++
+			return nil
+		case r.Error != "" || (r.Status != 200 && r.Status != 404):
+			rep.errors++
+			return nil
+		}
+		if !upgrade(cache, r) {
+			rep.stale++
+		}
+		c := rep.blocks[p]
+		if c == nil {
+			c = &blockCount{}
+			rep.blocks[p] = c
+		}
+		c.probed++
+		if r.Registered {
+			c.hits++
+		}
+		if r.Legacy {
+			c.legacy++
+		}
+		if rep.isTail(v) {
+			c.tail++
+		}
+		return nil
+	})
+	return rep, err
+}
+
+// isTail reports whether an unknown ISSN lies after the highest known
+// ISSN of its block, which is where frontier runs probe.
+func (rep *cacheReport) isTail(v issn.ISSN) bool {
+	p := v.Block()
+	return v.Prefix()-p*1000 >= 1000-rep.tailPool[p]
+}
+
+// total sums up all blocks.
+func (rep *cacheReport) total() (c blockCount, pool int) {
+	for _, b := range rep.blocks {
+		c.add(b)
+	}
+	for _, n := range rep.unknown {
+		pool += n
+	}
+	return c, pool
+}
+
+// biasWarnings compares the cached sample with what uniform random
+// sampling from the unknown pool would yield. A frontier run puts most
+// probes after the highest known ISSN of a block, a sparse run probes a
+// few blocks exhaustively; both skew the hit rate compared to the
+// pool. Both tests are z-scores, anything beyond 4 is unlikely by chance.
+func (rep *cacheReport) biasWarnings() []string {
+	var warnings []string
+	c, pool := rep.total()
+	if c.probed < 2 || pool == 0 {
+		return nil
+	}
+	n := float64(c.probed)
+	// Probes per block, chi-square against the pool share. The variance
+	// accounts for small expected counts.
+	var chi2, inv float64
+	var k int
+	for p, u := range rep.unknown {
+		if u == 0 {
+			continue
+		}
+		k++
+		pi := float64(u) / float64(pool)
+		exp := n * pi
+		obs := 0.0
+		if b := rep.blocks[p]; b != nil {
+			obs = float64(b.probed)
+		}
+		chi2 += (obs - exp) * (obs - exp) / exp
+		inv += 1 / pi
+	}
+	if k > 1 {
+		df := float64(k - 1)
+		variance := 2*df + (inv-float64(k*k)-2*float64(k)+2)/n
+		if variance > 0 {
+			if z := (chi2 - df) / math.Sqrt(variance); z > 4 {
+				warnings = append(warnings, fmt.Sprintf(
+					"probes are not spread over blocks like a random sample (z=%.1f), as from sparse runs", z))
+			}
+		}
+	}
+	// Share of probes in block tails.
+	var tailPool int
+	for _, n := range rep.tailPool {
+		tailPool += n
+	}
+	q := float64(tailPool) / float64(pool)
+	if q > 0 && q < 1 {
+		z := (float64(c.tail) - n*q) / math.Sqrt(n*q*(1-q))
+		if z > 4 {
+			warnings = append(warnings, fmt.Sprintf(
+				"%.0f%% of probes lie after the highest known ISSN of their block, expected %.0f%% (z=%.1f), as from frontier runs",
+				100*float64(c.tail)/n, 100*q, z))
+		}
+	}
+	return warnings
+}
+
+// writeTo writes per block and per stratum counts. Strata are given as
+// density cut-points, as in stratified mode.
+func (rep *cacheReport) writeTo(w io.Writer, density map[string]int, cuts []int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "block\tdensity\tunknown\tprobed\thits\tlegacy\tmiss\trate\t")
+	var keys []int
+	for p := range rep.blocks {
+		keys = append(keys, p)
+	}
+	sort.Ints(keys)
+	for _, p := range keys {
+		c := rep.blocks[p]
+		fmt.Fprintf(tw, "%04d\t%d\t%d\t%d\t%d\t%d\t%d\t%.3f\t\n",
+			p, density[fmt.Sprintf("%04d", p)], rep.unknown[p],
+			c.probed, c.hits, c.legacy, c.probed-c.hits-c.legacy, c.rate())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	type stratumCount struct {
+		lo, hi, blocks, unknown int
+		blockCount
+	}
+	var strata []*stratumCount
+	lo := 0
+	for _, c := range append(cuts, 1001) {
+		strata = append(strata, &stratumCount{lo: lo, hi: c - 1})
+		lo = c
+	}
+	for p := rep.pMin; p <= rep.pMax; p++ {
+		d := density[fmt.Sprintf("%04d", p)]
+		i := sort.Search(len(strata), func(i int) bool { return strata[i].hi >= d })
+		s := strata[i]
+		s.blocks++
+		s.unknown += rep.unknown[p]
+		if c := rep.blocks[p]; c != nil {
+			s.add(c)
+		}
+	}
+	fmt.Fprintln(tw, "stratum\tblocks\tunknown\tprobed\thits\tlegacy\tmiss\trate\tn_hat\t")
+	var nhat float64
+	for _, s := range strata {
+		v := s.rate() * float64(s.unknown)
+		nhat += v
+		fmt.Fprintf(tw, "%d-%d\t%d\t%d\t%d\t%d\t%d\t%d\t%.3f\t%.0f\t\n",
+			s.lo, s.hi, s.blocks, s.unknown, s.probed, s.hits, s.legacy,
+			s.probed-s.hits-s.legacy, s.rate(), v)
+	}
+	c, pool := rep.total()
+	fmt.Fprintf(tw, "total\t%d\t%d\t%d\t%d\t%d\t%d\t%.3f\t%.0f\t\n",
+		rep.pMax-rep.pMin+1, pool, c.probed, c.hits, c.legacy,
+		c.probed-c.hits-c.legacy, c.rate(), nhat)
+	return tw.Flush()
+}
+
+// logSummary logs what has been skipped and any bias warnings.
+func (rep *cacheReport) logSummary() {
+	log.Printf("report: scanned=%d outside=%d known=%d errors=%d",
+		rep.scanned, rep.outside, rep.known, rep.errors)
+	if rep.stale > 0 {
+		log.Printf("warning: %d results classified by an older schema without saved body", rep.stale)
+	}
+	for _, w := range rep.biasWarnings() {
+		log.Printf("warning: %s; the estimate is likely biased, use -mode estimate or stratified for a random sample", w)
+	}
+}
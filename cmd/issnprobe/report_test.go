@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/probecache"
+)
+
+func TestCacheReport(t *testing.T) {
+	var (
+		known = bitset.New()
+		cache = &probecache.Dir{Path: t.TempDir()}
+		put   = func(block, i, status int, registered bool) {
+			r := &probecache.Result{
+				ISSN:          issn.FromPrefix(block*1000 + i).String(),
+				Status:        status,
+				Registered:    registered,
+				SchemaVersion: schemaVersion,
+			}
+			if err := cache.PutResult(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+	)
+	// Block 5 is known up to 99, block 6 only at 500.
+	for i := 0; i < 100; i++ {
+		known.Add(issn.FromPrefix(5000 + i))
+	}
+	known.Add(issn.FromPrefix(6500))
+	for i := 100; i < 110; i++ {
+		put(5, i, http.StatusNotFound, i < 102)
+	}
+	for i := 1; i < 5; i++ {
+		put(6, i, http.StatusNotFound, false)
+	}
+	put(6, 600, http.StatusOK, true)
+	put(5, 50, http.StatusOK, true)                 // Known by now.
+	put(7, 1, http.StatusOK, true)                  // Outside.
+	put(6, 0, http.StatusServiceUnavailable, false) // Error.
+
+	rep, err := newCacheReport(cache, known, 5, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.scanned != 18 || rep.outside != 1 || rep.known != 1 || rep.errors != 1 || rep.stale != 0 {
+		t.Errorf("got scanned=%d outside=%d known=%d errors=%d stale=%d",
+			rep.scanned, rep.outside, rep.known, rep.errors, rep.stale)
+	}
+	var cases = []struct {
+		block                      int
+		unknown, tailPool          int
+		probed, hits, legacy, tail int
+	}{
+		{5, 900, 900, 10, 2, 0, 10},
+		{6, 999, 499, 5, 1, 0, 1},
+	}
+	for _, c := range cases {
+		b := rep.blocks[c.block]
+		if b == nil {
+			t.Fatalf("block %d: no counts", c.block)
+		}
+		if rep.unknown[c.block] != c.unknown || rep.tailPool[c.block] != c.tailPool {
+			t.Errorf("block %d: got pool %d, tail pool %d, want %d, %d",
+				c.block, rep.unknown[c.block], rep.tailPool[c.block], c.unknown, c.tailPool)
+		}
+		if b.probed != c.probed || b.hits != c.hits || b.legacy != c.legacy || b.tail != c.tail {
+			t.Errorf("block %d: got %+v", c.block, *b)
+		}
+	}
+	var sb strings.Builder
+	if err := rep.writeTo(&sb, map[string]int{"0005": 100, "0006": 1}, []int{10}); err != nil {
+		t.Fatal(err)
+	}
+	// Columns are compared without alignment.
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(sb.String(), "\n") {
+		lines[strings.Join(strings.Fields(line), " ")] = true
+	}
+	for _, want := range []string{
+		"0005 100 900 10 2 0 8 0.200",
+		"0006 1 999 5 1 0 4 0.200",
+		"0-9 1 999 5 1 0 4 0.200 200",
+		"10-1000 1 900 10 2 0 8 0.200 180",
+		"total 2 1899 15 3 0 12 0.200 380",
+	} {
+		if !lines[want] {
+			t.Errorf("report lacks %q:\n%s", want, sb.String())
+		}
+	}
+}
+
+func TestBiasWarnings(t *testing.T) {
+	// report returns a report over blocks with the same pool, tail pool
+	// and the given probes and tail probes per block.
+	report := func(pool, tailPool int, probes, tails []int) *cacheReport {
+		rep := &cacheReport{
+			blocks:   make(map[int]*blockCount),
+			unknown:  make(map[int]int),
+			tailPool: make(map[int]int),
+		}
+		for p := range probes {
+			rep.unknown[p], rep.tailPool[p] = pool, tailPool
+			if probes[p] > 0 {
+				rep.blocks[p] = &blockCount{probed: probes[p], tail: tails[p]}
+			}
+		}
+		return rep
+	}
+	var cases = []struct {
+		about string
+		rep   *cacheReport
+		want  []string // Substrings of the warnings, in order.
+	}{
+		{"random", report(1000, 500, []int{100, 100, 100, 100}, []int{50, 50, 50, 50}), nil},
+		{"frontier", report(1000, 100, []int{100, 100, 100, 100}, []int{100, 100, 100, 100}),
+			[]string{"after the highest known"}},
+		{"sparse", report(1000, 500, []int{400, 0, 0, 0}, []int{200, 0, 0, 0}),
+			[]string{"not spread over blocks"}},
+		{"too few probes", report(1000, 100, []int{1, 0, 0, 0}, []int{1, 0, 0, 0}), nil},
+	}
+	for _, c := range cases {
+		got := c.rep.biasWarnings()
+		if len(got) != len(c.want) {
+			t.Errorf("%s: got %q, want %d warnings", c.about, got, len(c.want))
+			continue
+		}
+		for i, w := range c.want {
+			if !strings.Contains(got[i], w) {
+				t.Errorf("%s: got %q, want %q", c.about, got[i], w)
+			}
+		}
+	}
+}
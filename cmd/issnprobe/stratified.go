@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miku/issnlister/bitset"
+)
+
+// Stratified estimation, see section 2.5 of
+// notes/2026-04-19-approximation.md. Blocks are grouped by density, a
+// pilot sample per stratum estimates its variance, and the remaining
+// budget is spread with Neyman allocation (n_i ∝ |U_i|·σ_i), which puts
+// most probes where hits are neither certain nor impossible.
+
+// stratum groups blocks of similar density with their unknown ISSN.
+type stratum struct {
+	lo, hi int      // density range, inclusive
+	blocks int      // number of blocks in range
+	pool   []string // unknown ISSN, shuffled
+	pilot  int      // planned pilot probes
+	target int      // planned probes in total
+	// observed
+	probes, hits, legacy int
+}
+
+func (s *stratum) name() string {
+	return fmt.Sprintf("%d-%d", s.lo, s.hi)
+}
+
+// sigma is the estimated standard deviation of a single probe. The
+// proportion is smoothed, so that a pilot without hits (or with only
+// hits) does not starve a stratum completely.
+func (s *stratum) sigma() float64 {
+	if s.probes == 0 {
+		return 0.5
+	}
+	p := (float64(s.hits) + 0.5) / (float64(s.probes) + 1)
+	return math.Sqrt(p * (1 - p))
+}
+
+// stratification keeps strata and the sampled candidates.
+type stratification struct {
+	strata []*stratum
+	index  map[string]*stratum // sampled candidate to stratum
+}
+
+// parseCuts parses ascending density cut-points like "100,900", which
+// yield the strata 0-99, 100-899 and 900-1000.
+func parseCuts(s string) ([]int, error) {
+	var cuts []int
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		v, err := strconv.Atoi(f)
+		if err != nil || v <= 0 || v > 1000 {
+			return nil, fmt.Errorf("bad cut-point: %q", f)
+		}
+		if len(cuts) > 0 && v <= cuts[len(cuts)-1] {
+			return nil, fmt.Errorf("cut-points must be ascending: %s", s)
+		}
+		cuts = append(cuts, v)
+	}
+	return cuts, nil
+}
+
+// newStratification assigns each block in [pMin..pMax] to a stratum by
+// density and collects the unknown ISSN per stratum in random order.
+func newStratification(known *bitset.Set, density map[string]int, pMin, pMax int, cuts []int, seed int64) *stratification {
+	s := &stratification{index: make(map[string]*stratum)}
+	lo := 0
+	for _, c := range append(cuts, 1001) {
+		s.strata = append(s.strata, &stratum{lo: lo, hi: c - 1})
+		lo = c
+	}
+	for p := pMin; p <= pMax; p++ {
+		prefix4 := fmt.Sprintf("%04d", p)
+		d := density[prefix4]
+		i := sort.Search(len(s.strata), func(i int) bool { return s.strata[i].hi >= d })
+		st := s.strata[i]
+		st.blocks++
+		for _, c := range blockCandidates(prefix4) {
+			if !known.ContainsString(c) {
+				st.pool = append(st.pool, c)
+			}
+		}
+	}
+	rng := rand.New(rand.NewSource(seed))
+	for _, st := range s.strata {
+		rng.Shuffle(len(st.pool), func(i, j int) { st.pool[i], st.pool[j] = st.pool[j], st.pool[i] })
+		log.Printf("stratum %s: blocks=%d pool=%d", st.name(), st.blocks, len(st.pool))
+	}
+	return s
+}
+
+// planPilot reserves up to n pilot probes per non-empty stratum, scaled
+// down if the budget does not allow it. At least two probes per stratum
+// are required.
+func (s *stratification) planPilot(n, budget int) error {
+	var nonEmpty int
+	for _, st := range s.strata {
+		if len(st.pool) > 0 {
+			nonEmpty++
+		}
+	}
+	if nonEmpty == 0 {
+		return fmt.Errorf("no unknown ISSN in range")
+	}
+	if n > budget/nonEmpty {
+		n = budget / nonEmpty
+	}
+	if n < 2 {
+		return fmt.Errorf("budget %d too small for %d strata", budget, nonEmpty)
+	}
+	for _, st := range s.strata {
+		st.pilot = min(n, len(st.pool))
+		st.target = st.pilot
+	}
+	return nil
+}
+
+// candidates returns the planned but not yet issued samples, interleaved
+// across strata, so that an interrupted run still covers all of them.
+func (s *stratification) candidates() []string {
+	var (
+		out  []string
+		next = make([]int, len(s.strata))
+	)
+	for more := true; more; {
+		more = false
+		for i, st := range s.strata {
+			for next[i] < st.target {
+				c := st.pool[next[i]]
+				next[i]++
+				if _, ok := s.index[c]; ok {
+					continue
+				}
+				s.index[c] = st
+				out = append(out, c)
+				more = true
+				break
+			}
+		}
+	}
+	return out
+}
+
+// allocate distributes the total budget with Neyman allocation, based on
+// the pilot results. No stratum gets less than its pilot.
+func (s *stratification) allocate(budget int) {
+	var (
+		size  = make([]int, len(s.strata))
+		floor = make([]int, len(s.strata))
+		sigma = make([]float64, len(s.strata))
+	)
+	for i, st := range s.strata {
+		size[i], floor[i], sigma[i] = len(st.pool), st.pilot, st.sigma()
+	}
+	for i, n := range neyman(budget, size, floor, sigma) {
+		s.strata[i].target = n
+	}
+}
+
+// neyman distributes n samples proportional to size[i]·sigma[i], with at
+// least floor[i] and at most size[i] samples per stratum. Strata hitting
+// a bound are fixed and the rest is distributed again.
+func neyman(n int, size, floor []int, sigma []float64) []int {
+	var (
+		k     = len(size)
+		alloc = make([]int, k)
+		fixed = make([]bool, k)
+		ideal = make([]float64, k)
+	)
+	weight := func(i int, allZero bool) float64 {
+		if allZero {
+			return float64(size[i])
+		}
+		return float64(size[i]) * sigma[i]
+	}
+	for {
+		rest := n
+		var w float64
+		for i := range size {
+			if fixed[i] {
+				rest -= alloc[i]
+			} else {
+				w += weight(i, false)
+			}
+		}
+		allZero := w == 0
+		if allZero {
+			for i := range size {
+				if !fixed[i] {
+					w += weight(i, true)
+				}
+			}
+		}
+		changed := false
+		for i := range size {
+			if fixed[i] {
+				continue
+			}
+			if w == 0 {
+				ideal[i] = 0
+			} else {
+				ideal[i] = float64(rest) * weight(i, allZero) / w
+			}
+			switch {
+			case ideal[i] <= float64(floor[i]):
+				alloc[i], fixed[i], changed = floor[i], true, true
+			case ideal[i] >= float64(size[i]):
+				alloc[i], fixed[i], changed = size[i], true, true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	// Round the free strata, largest remainder first.
+	var (
+		free []int
+		left = n
+	)
+	for i := range size {
+		if fixed[i] {
+			left -= alloc[i]
+			continue
+		}
+		alloc[i] = int(ideal[i])
+		left -= alloc[i]
+		free = append(free, i)
+	}
+	sort.Slice(free, func(a, b int) bool {
+		fa, fb := ideal[free[a]]-math.Floor(ideal[free[a]]), ideal[free[b]]-math.Floor(ideal[free[b]])
+		return fa > fb
+	})
+	for _, i := range free {
+		if left <= 0 {
+			break
+		}
+		if alloc[i] < size[i] {
+			alloc[i]++
+			left--
+		}
+	}
+	return alloc
+}
+
+// observe records a probe outcome for its stratum.
+func (s *stratification) observe(o outcome) {
+	st, ok := s.index[o.issn]
+	if !ok || o.err != nil {
+		return
+	}
+	st.probes++
+	if o.result.Registered {
+		st.hits++
+	}
+	if o.result.Legacy {
+		st.legacy++
+	}
+}
+
+// run probes the pilot sample, as returned by candidates, allocates the
+// remaining budget and probes the rest.
+func (s *stratification) run(ctx context.Context, p *Prober, pilot []string, workers, budget int, t *tally) runStats {
+	f := func(o outcome) bool {
+		s.observe(o)
+		return t.add(o)
+	}
+	stats := p.run(ctx, pilot, workers, f, nil)
+	if ctx.Err() != nil {
+		return stats
+	}
+	s.allocate(budget)
+	for _, st := range s.strata {
+		log.Printf("stratum %s: pilot=%d hits=%d sigma=%.3f allocated=%d",
+			st.name(), st.probes, st.hits, st.sigma(), st.target)
+	}
+	more := p.run(ctx, s.candidates(), workers, f, nil)
+	stats.elapsed += more.elapsed
+	stats.requests += more.requests
+	return stats
+}
+
+type stratumEstimate struct {
+	Name       string `json:"name"`
+	DensityMin int    `json:"density_min"`
+	DensityMax int    `json:"density_max"`
+	Blocks     int    `json:"blocks"`
+	Pilot      int    `json:"pilot"`
+	Allocated  int    `json:"allocated"`
+	estimate
+	Variance float64 `json:"variance"`
+}
+
+type stratifiedEstimate struct {
+	Probes     int               `json:"probes"`
+	Hits       int               `json:"hits"`
+	Legacy     int               `json:"legacy"`
+	PoolSize   int               `json:"pool_size"`
+	NHat       float64           `json:"n_hat"`
+	Variance   float64           `json:"variance"`
+	NormalLo   float64           `json:"normal_ci_lo"`
+	NormalHi   float64           `json:"normal_ci_hi"`
+	MarginAbs  float64           `json:"normal_margin_abs"`
+	LegacyNHat float64           `json:"legacy_n_hat"`
+	Strata     []stratumEstimate `json:"strata"`
+}
+
+// estimate combines the strata: N̂ = Σ p̂_i·|U_i| with variance
+// Σ |U_i|²·(1 − n_i/|U_i|)·p̃_i(1 − p̃_i)/n_i, i.e. including the finite
+// population correction, since small strata may be probed exhaustively.
+// The variance uses the smoothed proportion p̃_i of sigma, so that a
+// stratum without hits still adds its uncertainty. The interval of each
+// stratum is derived from the same variance.
+func (s *stratification) estimate() stratifiedEstimate {
+	var e stratifiedEstimate
+	for _, st := range s.strata {
+		size := len(st.pool)
+		se := stratumEstimate{
+			Name:       st.name(),
+			DensityMin: st.lo,
+			DensityMax: st.hi,
+			Blocks:     st.blocks,
+			Pilot:      st.pilot,
+			Allocated:  st.target,
+			estimate:   computeEstimate(st.hits, st.legacy, st.probes, size),
+		}
+		if st.probes > 0 {
+			se.Variance = stratumVariance(st.hits, st.probes, size)
+			se.estimate = withFPC(se.estimate, se.Variance)
+		}
+		e.Probes += st.probes
+		e.Hits += st.hits
+		e.Legacy += st.legacy
+		e.PoolSize += size
+		e.NHat += se.NHat
+		e.Variance += se.Variance
+		e.LegacyNHat += se.LegacyNHat
+		e.Strata = append(e.Strata, se)
+	}
+	e.MarginAbs = 1.96 * math.Sqrt(e.Variance)
+	e.NormalLo = math.Max(0, e.NHat-e.MarginAbs)
+	e.NormalHi = math.Min(float64(e.PoolSize), e.NHat+e.MarginAbs)
+	return e
+}
+
+// fpc returns the finite population correction for n of size probes.
+func fpc(n, size int) float64 {
+	if size == 0 {
+		return 0
+	}
+	return math.Max(0, 1-float64(n)/float64(size))
+}
+
+// stratumVariance returns the variance of N̂ for a stratum, with the
+// smoothed proportion and the finite population correction.
+func stratumVariance(hits, probes, size int) float64 {
+	p := (float64(hits) + 0.5) / (float64(probes) + 1)
+	return float64(size) * float64(size) * fpc(probes, size) * p * (1 - p) / float64(probes)
+}
+
+// withFPC replaces the intervals of a stratum estimate: the normal interval
+// with the given variance of N̂, the Wilson interval with the effective
+// sample size n/(1 − n/|U|). A stratum probed exhaustively has no
+// uncertainty left.
+func withFPC(e estimate, variance float64) estimate {
+	var (
+		z    = 1.96
+		pool = float64(e.PoolSize)
+		f    = fpc(e.Probes, e.PoolSize)
+	)
+	e.MarginAbs = z * math.Sqrt(variance)
+	e.NormalLo = math.Max(0, e.NHat-e.MarginAbs)
+	e.NormalHi = math.Min(pool, e.NHat+e.MarginAbs)
+	if f == 0 {
+		e.WilsonLo, e.WilsonHi, e.WilsonMargin = e.NHat, e.NHat, 0
+		return e
+	}
+	var (
+		p      = e.PHat
+		n      = float64(e.Probes) / f
+		denom  = 1 + z*z/n
+		centre = (p + z*z/(2*n)) / denom
+		half   = z * math.Sqrt(p*(1-p)/n+z*z/(4*n*n)) / denom
+	)
+	e.WilsonLo = math.Max(0, centre-half) * pool
+	e.WilsonHi = math.Min(1, centre+half) * pool
+	e.WilsonMargin = (e.WilsonHi - e.WilsonLo) / 2
+	return e
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestNeyman(t *testing.T) {
+	var cases = []struct {
+		about string
+		n     int
+		size  []int
+		floor []int
+		sigma []float64
+		want  []int
+	}{
+		{"proportional to size and sigma", 100, []int{1000, 1000}, []int{0, 0}, []float64{0.3, 0.1}, []int{75, 25}},
+		{"floor", 100, []int{1000, 1000}, []int{10, 10}, []float64{0.5, 0}, []int{90, 10}},
+		{"capped at size", 100, []int{30, 100}, []int{0, 0}, []float64{0.5, 0.05}, []int{30, 70}},
+		{"all sigma zero, by size", 10, []int{100, 400}, []int{0, 0}, []float64{0, 0}, []int{2, 8}},
+		{"rounding keeps the total", 10, []int{100, 100, 100}, []int{0, 0, 0}, []float64{0.5, 0.5, 0.5}, nil},
+	}
+	for _, c := range cases {
+		got := neyman(c.n, c.size, c.floor, c.sigma)
+		var sum int
+		for _, v := range got {
+			sum += v
+		}
+		if sum != c.n {
+			t.Errorf("%s: got %v, total %d, want %d", c.about, got, sum, c.n)
+		}
+		if c.want != nil && !slices.Equal(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.about, got, c.want)
+		}
+	}
+}
+
+// testStrata returns a stratification with pools of the given sizes.
+func testStrata(sizes ...int) *stratification {
+	s := &stratification{index: make(map[string]*stratum)}
+	for i, n := range sizes {
+		st := &stratum{lo: i * 100, hi: i*100 + 99}
+		for j := 0; j < n; j++ {
+			st.pool = append(st.pool, fmt.Sprintf("%d-%d", i, j))
+		}
+		s.strata = append(s.strata, st)
+	}
+	return s
+}
+
+func TestAllocate(t *testing.T) {
+	s := testStrata(1000, 1000, 50)
+	if err := s.planPilot(20, 300); err != nil {
+		t.Fatal(err)
+	}
+	// A mixed, an empty and a full stratum.
+	for i, hits := range []int{10, 0, 20} {
+		s.strata[i].probes, s.strata[i].hits = 20, hits
+	}
+	s.allocate(300)
+	var (
+		got   []int
+		total int
+	)
+	for _, st := range s.strata {
+		got = append(got, st.target)
+		total += st.target
+		if st.target < st.pilot {
+			t.Errorf("stratum %s: %d below pilot %d", st.name(), st.target, st.pilot)
+		}
+	}
+	if total != 300 {
+		t.Errorf("got %v, total %d, want 300", got, total)
+	}
+	if !(got[0] > got[1] && got[1] > got[2]) {
+		t.Errorf("got %v, want most probes in the mixed stratum", got)
+	}
+}
+
+func TestStratifiedEstimate(t *testing.T) {
+	s := testStrata(100, 1000)
+	// Probed exhaustively, and a sample without hits.
+	s.strata[0].probes, s.strata[0].hits = 100, 40
+	s.strata[1].probes, s.strata[1].hits = 50, 0
+	e := s.estimate()
+	if e.NHat != 40 || e.PoolSize != 1100 || e.Probes != 150 {
+		t.Errorf("got n_hat %v, pool %d, probes %d", e.NHat, e.PoolSize, e.Probes)
+	}
+	full, empty := e.Strata[0], e.Strata[1]
+	if full.Variance != 0 || full.NormalLo != 40 || full.NormalHi != 40 || full.WilsonLo != 40 || full.WilsonHi != 40 {
+		t.Errorf("exhaustive stratum: got %+v, want no uncertainty", full)
+	}
+	p := 0.5 / 51
+	want := 1000 * 1000 * 0.95 * p * (1 - p) / 50
+	if math.Abs(empty.Variance-want) > 1e-6 {
+		t.Errorf("stratum without hits: variance %v, want %v", empty.Variance, want)
+	}
+	if empty.NormalHi <= 0 || empty.WilsonHi <= 0 {
+		t.Errorf("stratum without hits: got interval %v-%v, wilson %v-%v", empty.NormalLo, empty.NormalHi, empty.WilsonLo, empty.WilsonHi)
+	}
+	for _, se := range e.Strata {
+		if math.Abs(se.MarginAbs-1.96*math.Sqrt(se.Variance)) > 1e-9 {
+			t.Errorf("stratum %s: margin %v does not match variance %v", se.Name, se.MarginAbs, se.Variance)
+		}
+	}
+	if e.Variance != full.Variance+empty.Variance {
+		t.Errorf("combined variance %v, want the sum of the strata", e.Variance)
+	}
+	if math.Abs(e.NormalHi-(40+1.96*math.Sqrt(want))) > 1e-6 {
+		t.Errorf("combined interval: got %v-%v", e.NormalLo, e.NormalHi)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/harvest"
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/probecache"
+	"github.com/miku/issnlister/record"
+)
+
+// meta is the part of a record served with a lookup.
+type meta struct {
+	ISSNL    string
+	KeyTitle string
+}
+
+// snapshot is a loaded list of registered ISSN.
+type snapshot struct {
+	filename string
+	date     time.Time // from the filename, otherwise the modification time
+	modTime  time.Time
+	size     int64
+	set      *bitset.Set
+	loaded   time.Time
+}
+
+// resolveSnapshot returns the snapshot file for a path: the path itself,
+// or for a directory the file with the latest date in its name, like
+// issn-2026-02-16.tsv.
+func resolveSnapshot(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return path, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, e := range entries {
+		if _, ok := issn.SnapshotDate(e.Name()); ok && !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) == 0 {
+		return "", errors.New("no dated snapshot in " + path)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ti, _ := issn.SnapshotDate(names[i])
+		tj, _ := issn.SnapshotDate(names[j])
+		return ti.Before(tj)
+	})
+	return filepath.Join(path, names[len(names)-1]), nil
+}
+
+// loadSnapshot reads a list or bitset file. Invalid lines are skipped, if
+// strict is false, otherwise they are an error.
+func loadSnapshot(filename string, strict bool) (*snapshot, error) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	set, err := bitset.ReadFile(filename)
+	if bitset.IsInvalid(err) && !strict {
+		log.Printf("%s: %v", filename, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	s := &snapshot{
+		filename: filename,
+		date:     fi.ModTime().UTC(),
+		modTime:  fi.ModTime(),
+		size:     fi.Size(),
+		set:      set,
+		loaded:   time.Now().UTC(),
+	}
+	if t, ok := issn.SnapshotDate(filepath.Base(filename)); ok {
+		s.date = t
+	}
+	return s, nil
+}
+
+// loadHarvest reads ISSN-L and key title from a harvest file.
+func loadHarvest(filename string) (map[issn.ISSN]meta, error) {
+	rc, err := harvest.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var (
+		m   = make(map[issn.ISSN]meta)
+		dec = record.NewDecoder(rc)
+	)
+	for {
+		r, err := dec.Decode()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		var le *record.LineError
+		if errors.As(err, &le) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		v, err := issn.Parse(r.ISSN)
+		if err != nil {
+			continue
+		}
+		m[v] = meta{ISSNL: r.ISSNL, KeyTitle: r.KeyTitle}
+	}
+	return m, nil
+}
+
+// index answers lookups. The snapshot can be swapped while serving.
+type index struct {
+	mu       sync.RWMutex
+	path     string // file or directory with snapshots
+	snapshot *snapshot
+	harvest  map[issn.ISSN]meta
+	probes   probecache.Cache
+	// Used by reload only.
+	pending  fileState // candidate seen at the last poll
+	rejected fileState // candidate, that failed to load
+}
+
+// fileState identifies a version of a snapshot file.
+type fileState struct {
+	filename string
+	size     int64
+	modTime  time.Time
+}
+
+func statFile(filename string) (fileState, error) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{filename: filename, size: fi.Size(), modTime: fi.ModTime()}, nil
+}
+
+func (ix *index) current() *snapshot {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.snapshot
+}
+
+// lookup returns metadata and its source, if any.
+func (ix *index) lookup(v issn.ISSN) (m meta, source string) {
+	if m, ok := ix.harvest[v]; ok {
+		return m, "harvest"
+	}
+	if ix.probes == nil {
+		return m, ""
+	}
+	body, err := ix.probes.Body(v.String())
+	if err != nil {
+		return m, ""
+	}
+	r, err := record.DecodeISSN(body, v.String())
+	if err != nil {
+		return m, ""
+	}
+	return meta{ISSNL: r.ISSNL, KeyTitle: r.KeyTitle}, "probecache"
+}
+
+// reload loads the snapshot, if a newer file showed up or the file has
+// been modified. It reports whether a new snapshot has been loaded.
+//
+// A file, that may still be written, is only loaded, once its size and
+// modification time are the same in two subsequent calls. The snapshot in
+// use is kept, if the new one contains invalid lines or cannot be read.
+// The first snapshot is loaded right away, skipping invalid lines.
+func (ix *index) reload() (bool, error) {
+	filename, err := resolveSnapshot(ix.path)
+	if err != nil {
+		return false, err
+	}
+	st, err := statFile(filename)
+	if err != nil {
+		return false, err
+	}
+	cur := ix.current()
+	if cur != nil {
+		switch st {
+		case fileState{cur.filename, cur.size, cur.modTime}, ix.rejected:
+			return false, nil
+		case ix.pending:
+		default:
+			// Changed since the last poll, wait for it to settle.
+			ix.pending = st
+			return false, nil
+		}
+	}
+	s, err := loadSnapshot(filename, cur != nil)
+	if err != nil {
+		if cur != nil {
+			ix.rejected = st
+			err = fmt.Errorf("%w, keeping %s", err, cur.filename)
+		}
+		return false, err
+	}
+	ix.mu.Lock()
+	ix.snapshot = s
+	ix.mu.Unlock()
+	return true, nil
+}
+
+// watch checks for a new snapshot periodically, until done is closed.
+func (ix *index) watch(interval time.Duration, done <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			ok, err := ix.reload()
+			switch {
+			case err != nil:
+				log.Printf("reload: %v", err)
+			case ok:
+				s := ix.current()
+				log.Printf("loaded %d ISSN from %s", s.set.Len(), s.filename)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reload := func(ix *index, want bool, wantErr bool) {
+		t.Helper()
+		ok, err := ix.reload()
+		if ok != want || (err != nil) != wantErr {
+			t.Fatalf("got %v, %v, want %v, error %v", ok, err, want, wantErr)
+		}
+	}
+	write("issn-2026-01-01.tsv", "0000-0019\n")
+	ix := &index{path: dir}
+	reload(ix, true, false)
+	reload(ix, false, false)
+
+	// A new snapshot is loaded, once it did not change between two polls.
+	write("issn-2026-02-01.tsv", "0000-0019\n1932-6203\n")
+	reload(ix, false, false)
+	reload(ix, true, false)
+	if n := ix.current().set.Len(); n != 2 {
+		t.Fatalf("got %d ISSN, want 2", n)
+	}
+
+	// A snapshot with invalid lines is rejected, once.
+	write("issn-2026-03-01.tsv", "0000-0019\n1932-620\n")
+	reload(ix, false, false)
+	reload(ix, false, true)
+	reload(ix, false, false)
+	if s := ix.current(); s.set.Len() != 2 || filepath.Base(s.filename) != "issn-2026-02-01.tsv" {
+		t.Fatalf("got %s with %d ISSN, want the previous snapshot", s.filename, s.set.Len())
+	}
+}
@@ -0,0 +1,213 @@
+// issnserve answers ISSN lookups over HTTP, from a list of registered ISSN
+// and optionally metadata from a harvest file or the issnprobe body cache.
+//
+//	$ issnserve -f issn.tsv -H data.ndj.gz
+//	$ curl localhost:8000/issn/0378-5955
+//	$ curl --data-binary @list.txt localhost:8000/check
+//
+// If -f is a directory, the snapshot with the latest date in its name is
+// used, e.g. issn-2026-02-16.tsv. New or modified snapshots are picked up
+// without a restart.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/miku/issnlister/checker"
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/probecache"
+)
+
+const version = "0.1.0"
+
+var (
+	listenAddr  = flag.String("addr", "localhost:8000", "address to listen on")
+	snapshotArg = flag.String("f", "issn.tsv", "registered ISSN, list or bitset file, or a directory of dated snapshots")
+	harvestFile = flag.String("H", "", "harvest file (NDJSON, optionally gzip) for ISSN-L and key title")
+	probeCache  = flag.String("probecache", "", "issnprobe cache directory or database for ISSN-L and key title (e.g. "+filepath.Join(xdg.CacheHome, "issnprobe")+")")
+	reloadEvery = flag.Duration("reload", time.Minute, "check for a new snapshot this often (0 = never)")
+	showVersion = flag.Bool("version", false, "show version")
+)
+
+// Result is the answer for a single ISSN.
+type Result struct {
+	Input      string `json:"input"`
+	ISSN       string `json:"issn,omitempty"` // normalized
+	Valid      bool   `json:"valid"`
+	Registered bool   `json:"registered"`
+	ISSNL      string `json:"issnl,omitempty"`
+	KeyTitle   string `json:"key_title,omitempty"`
+	Source     string `json:"source,omitempty"` // of metadata, harvest or probecache
+	Error      string `json:"error,omitempty"`
+}
+
+// check looks up a single ISSN in the given snapshot.
+func (ix *index) check(s *snapshot, input string) *Result {
+	r := &Result{Input: input}
+	v, err := issn.Parse(input)
+	if err != nil {
+		if n, nerr := issn.Normalize(input); nerr == nil {
+			r.ISSN = n
+		}
+		r.Error = err.Error()
+		return r
+	}
+	r.ISSN, r.Valid = v.String(), true
+	r.Registered = s.set.Contains(v)
+	m, source := ix.lookup(v)
+	r.ISSNL, r.KeyTitle, r.Source = m.ISSNL, m.KeyTitle, source
+	return r
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("write response: %v", err)
+	}
+}
+
+// handleISSN answers GET /issn/{issn}. Invalid input yields 400.
+func (ix *index) handleISSN(w http.ResponseWriter, r *http.Request) {
+	res := ix.check(ix.current(), r.PathValue("issn"))
+	status := http.StatusOK
+	if !res.Valid {
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, res)
+}
+
+// handleCheck answers POST /check with one ISSN per line. NDJSON input
+// (lines like "0378-5955" or {"issn": "0378-5955"}) yields NDJSON results
+// with metadata, any other input is checked like issncheck -i tsv, taking
+// the first column.
+func (ix *index) handleCheck(w http.ResponseWriter, r *http.Request) {
+	s := ix.current()
+	if !strings.Contains(r.Header.Get("Content-Type"), "json") {
+		w.Header().Set("Content-Type", "text/tab-separated-values; charset=utf-8")
+		c := &checker.Checker{Registered: s.set, Config: checker.Config{Input: checker.TSV, Output: checker.TSV, Column: 1}}
+		if _, err := c.Run(r.Body, w); err != nil {
+			log.Printf("check: %v", err)
+		}
+		return
+	}
+	var (
+		sc  = bufio.NewScanner(r.Body)
+		bw  = bufio.NewWriter(w)
+		enc = json.NewEncoder(bw)
+	)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if err := enc.Encode(ix.check(s, ndjsonInput(line))); err != nil {
+			return
+		}
+	}
+	if err := sc.Err(); err != nil {
+		log.Printf("check: %v", err)
+	}
+	bw.Flush()
+}
+
+// ndjsonInput extracts the ISSN from a JSON string or an object with an
+// issn field; anything else is taken as is.
+func ndjsonInput(line string) string {
+	var v string
+	if err := json.Unmarshal([]byte(line), &v); err == nil {
+		return v
+	}
+	var doc struct {
+		ISSN string `json:"issn"`
+	}
+	if err := json.Unmarshal([]byte(line), &doc); err == nil {
+		return doc.ISSN
+	}
+	return line
+}
+
+// handleHealth reports the loaded snapshot.
+func (ix *index) handleHealth(w http.ResponseWriter, r *http.Request) {
+	s := ix.current()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":   "ok",
+		"snapshot": s.filename,
+		"date":     s.date.Format("2006-01-02"),
+		"count":    s.set.Len(),
+		"loaded":   s.loaded.Format(time.RFC3339),
+		"metadata": len(ix.harvest),
+		"version":  version,
+	})
+}
+
+func main() {
+	flag.Parse()
+	if *showVersion {
+		fmt.Println(version)
+		os.Exit(0)
+	}
+	ix := &index{path: *snapshotArg}
+	if _, err := ix.reload(); err != nil {
+		log.Fatal(err)
+	}
+	s := ix.current()
+	log.Printf("loaded %d ISSN from %s", s.set.Len(), s.filename)
+	if *harvestFile != "" {
+		m, err := loadHarvest(*harvestFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ix.harvest = m
+		log.Printf("loaded metadata for %d ISSN from %s", len(m), *harvestFile)
+	}
+	if *probeCache != "" {
+		c, err := probecache.OpenReadOnly(*probeCache)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer c.Close()
+		ix.probes = c
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	if *reloadEvery > 0 {
+		go ix.watch(*reloadEvery, ctx.Done())
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /issn/{issn}", ix.handleISSN)
+	mux.HandleFunc("POST /check", ix.handleCheck)
+	mux.HandleFunc("GET /health", ix.handleHealth)
+	srv := &http.Server{
+		Addr:              *listenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	log.Printf("listening on %s", *listenAddr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
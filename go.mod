@@ -6,22 +6,11 @@ toolchain go1.24.2
 
 require (
 	github.com/adrg/xdg v0.5.3
-	github.com/miku/clam v0.1.0
+	github.com/klauspost/compress v1.18.0
 	github.com/miku/parallel v0.1.3
 	github.com/sethgrid/pester v1.2.0
 	github.com/sirupsen/logrus v1.9.3
-	github.com/vmihailenco/msgpack v4.0.4+incompatible
+	go.etcd.io/bbolt v1.4.3
 )
 
-require (
-	github.com/fatih/color v1.18.0 // indirect
-	github.com/golang/protobuf v1.5.4 // indirect
-	github.com/hoisie/mustache v0.0.0-20160804235033-6375acf62c69 // indirect
-	github.com/kr/pretty v0.1.0 // indirect
-	github.com/mattn/go-colorable v0.1.14 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
-	golang.org/x/sys v0.39.0 // indirect
-	google.golang.org/appengine v1.6.8 // indirect
-	google.golang.org/protobuf v1.36.11 // indirect
-	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
-)
+require golang.org/x/sys v0.39.0 // indirect
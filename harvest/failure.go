@@ -0,0 +1,106 @@
+package harvest
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Failure records an ISSN, that could not be fetched, even after retries.
+type Failure struct {
+	ISSN     string    `json:"issn"`
+	URL      string    `json:"url"`
+	Status   int       `json:"status,omitempty"` // Last HTTP status, if any.
+	Body     string    `json:"body,omitempty"`   // Start of the last response body.
+	Error    string    `json:"error,omitempty"`
+	Attempts int       `json:"attempts"`
+	Time     time.Time `json:"time"`
+}
+
+// FailureLog appends failures to a file, one JSON object per line. It is
+// safe for concurrent use.
+type FailureLog struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+	n   int
+}
+
+// OpenFailureLog opens a failure log for appending.
+func OpenFailureLog(filename string) (*FailureLog, error) {
+	return openFailureLog(filename, os.O_APPEND)
+}
+
+// CreateFailureLog creates a failure log, truncating an existing file.
+func CreateFailureLog(filename string) (*FailureLog, error) {
+	return openFailureLog(filename, os.O_TRUNC)
+}
+
+func openFailureLog(filename string, flag int) (*FailureLog, error) {
+	f, err := os.OpenFile(filename, flag|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FailureLog{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Add appends a failure to the log.
+func (l *FailureLog) Add(f *Failure) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.n++
+	return l.enc.Encode(f)
+}
+
+// Len returns the number of failures added since the log was opened.
+func (l *FailureLog) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.n
+}
+
+// Close closes the underlying file.
+func (l *FailureLog) Close() error {
+	return l.f.Close()
+}
+
+// ReadFailures reads all failures from a log. If an ISSN failed more than
+// once, only the last failure is kept. A missing file is not an error.
+func ReadFailures(filename string) ([]*Failure, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		result []*Failure
+		index  = make(map[string]int)
+		br     = bufio.NewReader(f)
+	)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var v Failure
+		if err := json.Unmarshal(line, &v); err != nil {
+			continue
+		}
+		if i, ok := index[v.ISSN]; ok {
+			result[i] = &v
+			continue
+		}
+		index[v.ISSN] = len(result)
+		result = append(result, &v)
+	}
+	return result, nil
+}
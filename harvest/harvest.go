@@ -0,0 +1,325 @@
+// Package harvest implements reading and resumable writing of metadata
+// harvests, as written by issnlister -m: one JSON-LD document per line,
+// optionally gzip compressed, if the filename ends with .gz.
+//
+// A harvest written with Writer has a sidecar journal (the filename with a
+// .journal suffix), which lists each finished ISSN with the file offset after
+// which its record is complete. Resume uses the journal to find finished ISSN
+// quickly and falls back to scanning the file, if the journal is missing or
+// does not match the file.
+package harvest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/miku/issnlister/atomic"
+	"github.com/miku/issnlister/bitset"
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/record"
+)
+
+// IsCompressed returns true, if a harvest file is gzip compressed.
+func IsCompressed(filename string) bool {
+	return strings.HasSuffix(filename, ".gz")
+}
+
+// JournalFile returns the name of the journal for a harvest file.
+func JournalFile(filename string) string {
+	return filename + ".journal"
+}
+
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (rc *readCloser) Close() error {
+	var err error
+	for _, c := range rc.closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Open opens a harvest file for reading, decompressing it if necessary.
+func Open(filename string) (io.ReadCloser, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !IsCompressed(filename) {
+		return f, nil
+	}
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if err == io.EOF {
+		// An empty file is an empty harvest.
+		return &readCloser{Reader: strings.NewReader(""), closers: []io.Closer{f}}, nil
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &readCloser{Reader: zr, closers: []io.Closer{zr, f}}, nil
+}
+
+// entry is a single journal line: a finished ISSN and the file offset, after
+// which its record is complete.
+type entry struct {
+	offset int64
+	issn   string
+}
+
+// readJournal reads all complete journal lines.
+func readJournal(filename string) ([]entry, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		entries []entry
+		br      = bufio.NewReader(f)
+	)
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			// Ignore a partial last line.
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		offset, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry{offset: offset, issn: fields[1]})
+	}
+	return entries, nil
+}
+
+// writeJournal atomically replaces a journal.
+func writeJournal(filename string, entries []entry) error {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		fmt.Fprintf(&buf, "%d\t%s\n", e.offset, e.issn)
+	}
+	return atomic.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// recordISSN returns the ISSN of a single harvested document or the empty
+// string.
+func recordISSN(line []byte) string {
+	r, err := record.Decode(line)
+	if err != nil {
+		return ""
+	}
+	return r.ISSN
+}
+
+// countingReader counts the bytes consumed from the underlying reader. It
+// implements io.ByteReader, so the gzip reader does not read ahead and the
+// count is exact at the end of each gzip member.
+type countingReader struct {
+	br *bufio.Reader
+	n  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.br.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func (r *countingReader) ReadByte() (byte, error) {
+	b, err := r.br.ReadByte()
+	if err == nil {
+		r.n++
+	}
+	return b, err
+}
+
+// scan reads complete records from a file starting at offset and returns
+// the journal entries for them together with the offset after the last
+// complete record (for compressed files: the last complete gzip member).
+// Members are streamed line by line. A member, that is intact but does not
+// end with a newline, is kept; open reports it, so a newline can be added
+// before appending.
+func scan(f *os.File, offset int64, compressed bool) (entries []entry, end int64, open bool, err error) {
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, 0, false, err
+	}
+	if !compressed {
+		br := bufio.NewReader(f)
+		for {
+			line, err := br.ReadBytes('\n')
+			if err == io.EOF {
+				return entries, offset, false, nil
+			}
+			if err != nil {
+				return nil, 0, false, err
+			}
+			offset += int64(len(line))
+			if v := recordISSN(line); v != "" {
+				entries = append(entries, entry{offset: offset, issn: v})
+			}
+		}
+	}
+	var (
+		cr    = &countingReader{br: bufio.NewReader(f), n: offset}
+		zr    gzip.Reader
+		br    = bufio.NewReader(nil)
+		found []string // ISSN in the current member.
+	)
+	for {
+		if err := zr.Reset(cr); err != nil {
+			// io.EOF at the end of the file, anything else is a partial
+			// member header.
+			return entries, offset, open, nil
+		}
+		zr.Multistream(false)
+		br.Reset(&zr)
+		found = found[:0]
+		var last byte = '\n'
+		for {
+			line, err := br.ReadBytes('\n')
+			if len(line) > 0 {
+				last = line[len(line)-1]
+				if v := recordISSN(line); v != "" {
+					found = append(found, v)
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				// A truncated or corrupt member, stop here.
+				return entries, offset, open, nil
+			}
+		}
+		offset, open = cr.n, last != '\n'
+		for _, v := range found {
+			entries = append(entries, entry{offset: offset, issn: v})
+		}
+	}
+}
+
+// validCheckpoint returns true, if offset looks like the end of a complete
+// record in f: the end of a line or, for compressed files, the end of the
+// file or the start of the next gzip member. Whether the member before a
+// compressed checkpoint is intact is only known after decompressing it; the
+// journal is trusted for that.
+func validCheckpoint(f *os.File, offset int64, compressed bool) bool {
+	if offset == 0 {
+		return true
+	}
+	if compressed {
+		fi, err := f.Stat()
+		if err != nil {
+			return false
+		}
+		if offset == fi.Size() {
+			return true
+		}
+		b := make([]byte, 2)
+		if _, err := f.ReadAt(b, offset); err != nil {
+			return false
+		}
+		return b[0] == 0x1f && b[1] == 0x8b
+	}
+	b := make([]byte, 1)
+	if _, err := f.ReadAt(b, offset-1); err != nil {
+		return false
+	}
+	return b[0] == '\n'
+}
+
+// appendNewline writes a gzip member with a single newline at offset.
+func appendNewline(f *os.File, offset int64) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte("\n")); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	_, err := f.WriteAt(buf.Bytes(), offset)
+	return err
+}
+
+// Resume prepares a harvest file for appending and returns the set of ISSN
+// already harvested. The file is truncated after the last complete record,
+// so a partial last line from an interrupted run is dropped, and the journal
+// is rewritten to match the file. A missing file is created.
+func Resume(filename string) (*bitset.Set, error) {
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	compressed := IsCompressed(filename)
+	entries, err := readJournal(JournalFile(filename))
+	if err != nil {
+		return nil, err
+	}
+	// Keep journal entries up to the last plausible checkpoint.
+	var checkpoint int64
+	for i, e := range entries {
+		if e.offset < checkpoint || e.offset > fi.Size() {
+			entries = entries[:i]
+			break
+		}
+		checkpoint = e.offset
+	}
+	if !validCheckpoint(f, checkpoint, compressed) {
+		entries, checkpoint = nil, 0
+	}
+	more, end, open, err := scan(f, checkpoint, compressed)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, more...)
+	if end < fi.Size() {
+		if err := f.Truncate(end); err != nil {
+			return nil, err
+		}
+	}
+	if open {
+		// The last member ends without newline, e.g. from another tool;
+		// terminate its last line, so appended records start on a new line.
+		if err := appendNewline(f, end); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeJournal(JournalFile(filename), entries); err != nil {
+		return nil, err
+	}
+	set := bitset.New()
+	for _, e := range entries {
+		if v, err := issn.Parse(e.issn); err == nil {
+			set.Add(v)
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,85 @@
+package harvest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func member(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func doc(v string) string {
+	return `{"@graph": [{"@id": "resource/ISSN/` + v + `", "mainTitle": "T"}]}`
+}
+
+func TestResumeCompressed(t *testing.T) {
+	var (
+		filename = filepath.Join(t.TempDir(), "data.ndj.gz")
+		first    = member(t, doc("0000-0019")+"\n"+doc("1932-6203")+"\n")
+		second   = member(t, doc("2257-6754")) // No trailing newline.
+		third    = member(t, doc("0378-5955")+"\n")
+		data     = append(append(append([]byte{}, first...), second...), third[:len(third)-6]...)
+	)
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	set, err := Resume(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"0000-0019", "1932-6203", "2257-6754"} {
+		if !set.ContainsString(v) {
+			t.Errorf("%s missing", v)
+		}
+	}
+	if set.ContainsString("0378-5955") {
+		t.Errorf("record of truncated member found")
+	}
+	w, err := NewWriter(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(doc("0378-5955") + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(b, []byte("\n")); n != 4 {
+		t.Errorf("got %d lines, want 4: %s", n, b)
+	}
+	for _, line := range bytes.Split(bytes.TrimSpace(b), []byte("\n")) {
+		if recordISSN(line) == "" {
+			t.Errorf("invalid line: %s", line)
+		}
+	}
+	// A second resume finds all records via the journal.
+	if set, err = Resume(filename); err != nil {
+		t.Fatal(err)
+	}
+	if n := set.Len(); n != 4 {
+		t.Errorf("got %d after resume, want 4", n)
+	}
+}
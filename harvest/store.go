@@ -0,0 +1,61 @@
+package harvest
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/store"
+)
+
+// StoreWriter writes harvested records to a record store, as a new version
+// of each ISSN. Data may be written in arbitrary chunks, like for Writer.
+// Lines without an ISSN are counted and dropped.
+type StoreWriter struct {
+	mu      sync.Mutex
+	s       *store.Store
+	pending []byte // Incomplete line.
+	Skipped int
+}
+
+// NewStoreWriter returns a writer to a store, which is closed with the
+// writer.
+func NewStoreWriter(s *store.Store) *StoreWriter {
+	return &StoreWriter{s: s}
+}
+
+// Write stores complete lines.
+func (w *StoreWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, p...)
+	i := bytes.LastIndexByte(w.pending, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	now := time.Now()
+	for _, line := range bytes.Split(w.pending[:i], []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		v, err := issn.Parse(recordISSN(line))
+		if err != nil {
+			w.Skipped++
+			continue
+		}
+		if err := w.s.Put(v, now, line); err != nil {
+			return 0, err
+		}
+	}
+	w.pending = append(w.pending[:0], w.pending[i+1:]...)
+	return len(p), nil
+}
+
+// Close writes pending data and closes the store. A trailing incomplete
+// line is dropped.
+func (w *StoreWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.s.Close()
+}
@@ -0,0 +1,119 @@
+package harvest
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/miku/issnlister/atomic"
+)
+
+// Validator describes the last response for an ISSN, for a conditional
+// request on the next refresh: ETag and Last-Modified as sent by the
+// server, the hash of the harvested record and the time of the last check.
+type Validator struct {
+	ISSN         string    `json:"issn"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Hash         string    `json:"hash,omitempty"`
+	Checked      time.Time `json:"checked"`
+}
+
+// ValidatorsFile returns the name of the validator log of a harvest file or
+// record store.
+func ValidatorsFile(filename string) string {
+	return filename + ".validators"
+}
+
+// ValidatorLog appends validators to a file, one JSON object per line; a
+// later line for an ISSN replaces earlier ones. It is safe for concurrent
+// use.
+type ValidatorLog struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// OpenValidatorLog opens a validator log for appending.
+func OpenValidatorLog(filename string) (*ValidatorLog, error) {
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &ValidatorLog{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Add appends a validator to the log.
+func (l *ValidatorLog) Add(v *Validator) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(v)
+}
+
+// Close closes the underlying file.
+func (l *ValidatorLog) Close() error {
+	return l.f.Close()
+}
+
+// ReadValidators reads a validator log, keeping the last validator per
+// ISSN. If keep is not nil, only validators for which it returns true are
+// kept. A missing file is not an error.
+func ReadValidators(filename string, keep func(issn string) bool) (map[string]*Validator, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		result = make(map[string]*Validator)
+		br     = bufio.NewReader(f)
+	)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			// Ignore a partial last line.
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var v Validator
+		if err := json.Unmarshal(line, &v); err != nil || v.ISSN == "" {
+			continue
+		}
+		if keep != nil && !keep(v.ISSN) {
+			continue
+		}
+		result[v.ISSN] = &v
+	}
+}
+
+// CompactValidators atomically rewrites a validator log with only the last
+// validator per ISSN, in ISSN order.
+func CompactValidators(filename string) error {
+	vs, err := ReadValidators(filename, nil)
+	if err != nil || vs == nil {
+		return err
+	}
+	keys := make([]string, 0, len(vs))
+	for k := range vs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf []byte
+	for _, k := range keys {
+		b, err := json.Marshal(vs[k])
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, b...), '\n')
+	}
+	return atomic.WriteFile(filename, buf, 0644)
+}
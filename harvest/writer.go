@@ -0,0 +1,143 @@
+package harvest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// flushSize is the amount of uncompressed data collected before a gzip
+// member is written. Records in an unwritten member are lost on a crash and
+// harvested again on resume.
+const flushSize = 1 << 20
+
+// Writer appends records to a harvest file and keeps the journal. Data may
+// be written in arbitrary chunks, only complete lines reach the file.
+type Writer struct {
+	mu         sync.Mutex
+	f          *os.File
+	journal    *os.File
+	compressed bool
+	offset     int64        // Current size of the file.
+	pending    []byte       // Incomplete line.
+	buf        bytes.Buffer // Complete lines not yet written to a member.
+	issns      []string     // ISSN of the lines in buf.
+}
+
+// NewWriter opens a harvest file for appending. Call Resume first, so the file
+// ends with a complete record and the journal matches the file.
+func NewWriter(filename string) (*Writer, error) {
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	journal, err := os.OpenFile(JournalFile(filename), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Writer{
+		f:          f,
+		journal:    journal,
+		compressed: IsCompressed(filename),
+		offset:     fi.Size(),
+	}, nil
+}
+
+// Write collects data and writes complete lines to the file.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, p...)
+	i := bytes.LastIndexByte(w.pending, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	for _, line := range bytes.SplitAfter(w.pending[:i+1], []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		w.buf.Write(line)
+		w.issns = append(w.issns, recordISSN(line))
+	}
+	w.pending = append(w.pending[:0], w.pending[i+1:]...)
+	if !w.compressed || w.buf.Len() >= flushSize {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes the collected lines, as a gzip member for compressed files,
+// then records the finished ISSN in the journal.
+func (w *Writer) flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	data := w.buf.Bytes()
+	if w.compressed {
+		var zbuf bytes.Buffer
+		zw := gzip.NewWriter(&zbuf)
+		if _, err := zw.Write(data); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		data = zbuf.Bytes()
+	}
+	if _, err := w.f.Write(data); err != nil {
+		return err
+	}
+	var (
+		jbuf bytes.Buffer
+		end  = w.offset + int64(len(data))
+		off  = w.offset
+	)
+	// For uncompressed files, each record ends at its own offset, for
+	// compressed files at the end of the member.
+	for _, line := range bytes.SplitAfter(w.buf.Bytes(), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		off += int64(len(line))
+		v := w.issns[0]
+		w.issns = w.issns[1:]
+		if v == "" {
+			continue
+		}
+		if w.compressed {
+			fmt.Fprintf(&jbuf, "%d\t%s\n", end, v)
+		} else {
+			fmt.Fprintf(&jbuf, "%d\t%s\n", off, v)
+		}
+	}
+	w.offset = end
+	w.buf.Reset()
+	w.issns = w.issns[:0]
+	_, err := w.journal.Write(jbuf.Bytes())
+	return err
+}
+
+// Close writes any remaining data and closes the file and journal. A
+// trailing incomplete line is dropped.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.flush()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := w.journal.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,240 @@
+// Package issn implements parsing, normalization and check digit
+// computation for International Standard Serial Numbers.
+//
+// An ISSN consists of seven digits and a check digit, which is either a digit
+// or the letter X. The canonical form is NNNN-NNNC, e.g. 2049-3630.
+package issn
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// MaxPrefix is the largest seven digit prefix; there are MaxPrefix + 1
+// checksum-valid ISSN in total.
+const MaxPrefix = 9999999
+
+var (
+	// ErrEmpty is returned for empty or whitespace only input.
+	ErrEmpty = errors.New("empty input")
+	// ErrInvalidChar is returned if the input contains a character, that
+	// cannot be part of an ISSN.
+	ErrInvalidChar = errors.New("invalid character")
+	// ErrLength is returned if the input does not contain exactly eight
+	// digits (the last one may be an X).
+	ErrLength = errors.New("wrong number of digits")
+	// ErrHyphen is returned if a separator is found at an unexpected
+	// position, e.g. 123-45678.
+	ErrHyphen = errors.New("misplaced separator")
+	// ErrCheckDigit is returned if the check digit does not match the
+	// first seven digits.
+	ErrCheckDigit = errors.New("check digit mismatch")
+)
+
+// Error describes why a value was rejected. Use errors.Is with one of the
+// Err* values to find out the reason.
+type Error struct {
+	Input string // Original input.
+	Err   error  // One of the Err* values.
+	Want  byte   // Expected check digit, only set for ErrCheckDigit.
+}
+
+func (e *Error) Error() string {
+	if e.Err == ErrCheckDigit {
+		return fmt.Sprintf("issn: %q: %v, want %c", e.Input, e.Err, e.Want)
+	}
+	return fmt.Sprintf("issn: %q: %v", e.Input, e.Err)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// ISSN is a checksum-valid ISSN, stored as its seven digit prefix, e.g. the
+// ISSN 2049-3630 is stored as 2049363. The zero value is 0000-0000.
+type ISSN uint32
+
+// FromPrefix returns the ISSN for a seven digit prefix. Panics if the prefix
+// is out of range.
+func FromPrefix(prefix int) ISSN {
+	if prefix < 0 || prefix > MaxPrefix {
+		panic(fmt.Sprintf("issn: prefix out of range: %d", prefix))
+	}
+	return ISSN(prefix)
+}
+
+// Prefix returns the seven digit prefix as an integer.
+func (v ISSN) Prefix() int { return int(v) }
+
+// Block returns the four digit block, e.g. 2049 for 2049-3630.
+func (v ISSN) Block() int { return int(v) / 1000 }
+
+// CheckDigit returns the check digit, '0'-'9' or 'X'.
+func (v ISSN) CheckDigit() byte {
+	var (
+		n   = int(v)
+		sum = 0
+	)
+	// Weights are 8 (first digit) down to 2 (seventh digit).
+	for w := 2; w <= 8; w++ {
+		sum += (n % 10) * w
+		n /= 10
+	}
+	return checkChar(sum)
+}
+
+// String returns the canonical, hyphenated form, e.g. 2049-3630.
+func (v ISSN) String() string {
+	s := v.Compact()
+	return s[:4] + "-" + s[4:]
+}
+
+// Compact returns the form without hyphen, e.g. 20493630.
+func (v ISSN) Compact() string {
+	return fmt.Sprintf("%07d%c", int(v), v.CheckDigit())
+}
+
+// CheckDigit computes the check digit for a string of seven ASCII digits.
+func CheckDigit(seven string) (byte, error) {
+	if len(seven) != 7 {
+		return 0, &Error{Input: seven, Err: ErrLength}
+	}
+	sum := 0
+	for i := 0; i < 7; i++ {
+		c := seven[i]
+		if c < '0' || c > '9' {
+			return 0, &Error{Input: seven, Err: ErrInvalidChar}
+		}
+		sum += int(c-'0') * (8 - i)
+	}
+	return checkChar(sum), nil
+}
+
+func checkChar(sum int) byte {
+	switch mod := sum % 11; mod {
+	case 0:
+		return '0'
+	case 1:
+		return 'X'
+	default:
+		return byte('0' + 11 - mod)
+	}
+}
+
+// Normalize cleans up a value and returns it in canonical NNNN-NNNC form,
+// without looking at the check digit. It accepts the forms found in the
+// wild, e.g. "ISSN 0378 5955", "urn:issn:0378-5955", "0378–5955" (with an en
+// dash), lowercase x or full-width digits. Use Parse, if the check digit
+// should be verified as well.
+func Normalize(s string) (string, error) {
+	b, err := clean(s)
+	if err != nil {
+		return "", err
+	}
+	return string(b[:4]) + "-" + string(b[4:]), nil
+}
+
+// Parse normalizes a value like Normalize and verifies the check digit.
+func Parse(s string) (ISSN, error) {
+	b, err := clean(s)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, c := range b[:7] {
+		n = n*10 + int(c-'0')
+	}
+	v := ISSN(n)
+	if want := v.CheckDigit(); b[7] != want {
+		return 0, &Error{Input: s, Err: ErrCheckDigit, Want: want}
+	}
+	return v, nil
+}
+
+// MustParse is like Parse, but panics on invalid input.
+func MustParse(s string) ISSN {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Valid returns true, if the value can be parsed and has a correct check
+// digit.
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// prefixes, which are commonly found in front of an ISSN, lowercase and
+// longest first.
+var prefixes = []string{
+	"urn:issn:",
+	"issn-l",
+	"e-issn",
+	"p-issn",
+	"eissn",
+	"pissn",
+	"issn",
+}
+
+// clean returns the eight significant characters of an ISSN, seven ASCII
+// digits and a digit or uppercase X.
+func clean(s string) ([]byte, error) {
+	t := strings.TrimSpace(s)
+	if t == "" {
+		return nil, &Error{Input: s, Err: ErrEmpty}
+	}
+	lower := strings.ToLower(t)
+	for _, p := range prefixes {
+		if strings.HasPrefix(lower, p) {
+			t = strings.TrimLeft(t[len(p):], ": \t")
+			break
+		}
+	}
+	var (
+		b   = make([]byte, 0, 8)
+		sep = -1 // Number of digits seen, when a separator was encountered.
+	)
+	for _, r := range t {
+		switch {
+		case r >= '0' && r <= '9':
+			b = append(b, byte(r))
+		case r >= '０' && r <= '９':
+			b = append(b, byte(r-'０'+'0'))
+		case r == 'x' || r == 'X' || r == 'ｘ' || r == 'Ｘ':
+			if len(b) != 7 {
+				return nil, &Error{Input: s, Err: ErrInvalidChar}
+			}
+			b = append(b, 'X')
+		case isDash(r) || unicode.IsSpace(r):
+			if sep >= 0 && sep != len(b) {
+				return nil, &Error{Input: s, Err: ErrHyphen}
+			}
+			sep = len(b)
+		default:
+			return nil, &Error{Input: s, Err: ErrInvalidChar}
+		}
+		if len(b) > 8 {
+			return nil, &Error{Input: s, Err: ErrLength}
+		}
+	}
+	if len(b) != 8 {
+		return nil, &Error{Input: s, Err: ErrLength}
+	}
+	if sep >= 0 && sep != 4 {
+		return nil, &Error{Input: s, Err: ErrHyphen}
+	}
+	return b, nil
+}
+
+// isDash reports whether r is a hyphen or dash like character.
+func isDash(r rune) bool {
+	switch r {
+	case '-', '‐', '‑', '‒', '–', '—', '―',
+		'−', '﹘', '﹣', '－':
+		return true
+	}
+	return false
+}
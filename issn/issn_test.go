@@ -0,0 +1,90 @@
+package issn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	var cases = []struct {
+		s    string
+		want string // Normalized form, empty if Normalize fails.
+		err  error  // Error of Parse.
+	}{
+		{"0378-5955", "0378-5955", nil},
+		{"03785955", "0378-5955", nil},
+		{" ISSN 0378 5955 ", "0378-5955", nil},
+		{"urn:issn:0378-5955", "0378-5955", nil},
+		{"e-ISSN: 0378–5955", "0378-5955", nil},
+		{"2434-561X", "2434-561X", nil},
+		{"2434-561x", "2434-561X", nil},
+		{"２４３４－５６１Ｘ", "2434-561X", nil},
+		{"0000-0019", "0000-0019", nil},
+		{"0378-5954", "0378-5954", ErrCheckDigit},
+		{"2434-5610", "2434-5610", ErrCheckDigit},
+		{"0378-595X", "0378-595X", ErrCheckDigit},
+		{"", "", ErrEmpty},
+		{"  ", "", ErrEmpty},
+		{"0378-595", "", ErrLength},
+		{"0378-59555", "", ErrLength},
+		{"037-85955", "", ErrHyphen},
+		{"0378-59a5", "", ErrInvalidChar},
+		{"X378-5955", "", ErrInvalidChar},
+	}
+	for _, c := range cases {
+		n, err := Normalize(c.s)
+		if c.want == "" {
+			if err == nil {
+				t.Errorf("Normalize(%q): got %s, want error", c.s, n)
+			}
+		} else if err != nil || n != c.want {
+			t.Errorf("Normalize(%q): got %s, %v, want %s", c.s, n, err, c.want)
+		}
+		v, err := Parse(c.s)
+		if c.err == nil {
+			if err != nil || v.String() != c.want {
+				t.Errorf("Parse(%q): got %s, %v, want %s", c.s, v, err, c.want)
+			}
+			continue
+		}
+		if !errors.Is(err, c.err) {
+			t.Errorf("Parse(%q): got %v, want %v", c.s, err, c.err)
+		}
+		if Valid(c.s) {
+			t.Errorf("Valid(%q): got true", c.s)
+		}
+	}
+	var e *Error
+	if _, err := Parse("0378-5954"); !errors.As(err, &e) || e.Want != '5' {
+		t.Errorf("want check digit 5 in error, got %v", err)
+	}
+}
+
+func TestCheckDigit(t *testing.T) {
+	var cases = []struct {
+		seven string
+		want  byte
+		err   error
+	}{
+		{"0378595", '5', nil},
+		{"2434561", 'X', nil},
+		{"0000000", '0', nil},
+		{"0000001", '9', nil},
+		{"9999999", '4', nil},
+		{"037859", 0, ErrLength},
+		{"03785a5", 0, ErrInvalidChar},
+	}
+	for _, c := range cases {
+		got, err := CheckDigit(c.seven)
+		if !errors.Is(err, c.err) || got != c.want {
+			t.Errorf("CheckDigit(%q): got %q, %v, want %q, %v", c.seven, got, err, c.want, c.err)
+		}
+		if c.err != nil {
+			continue
+		}
+		v := MustParse(c.seven + string(c.want))
+		if v.CheckDigit() != c.want {
+			t.Errorf("%s: CheckDigit: got %q, want %q", v, v.CheckDigit(), c.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package issn
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// snapshotDateRe matches the date in the name of a list snapshot, with or
+// without dashes.
+var snapshotDateRe = regexp.MustCompile(`(\d{4})-?(\d{2})-?(\d{2})`)
+
+// SnapshotDate returns the date in the name of a list snapshot, like
+// issn-2026-02-16.tsv, 20260216.issn.tsv or a cache directory 2026-02-16.
+// It reports false, if the name contains no valid date.
+func SnapshotDate(name string) (time.Time, bool) {
+	m := snapshotDateRe.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("20060102", m[1]+m[2]+m[3])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// SnapshotFileDate returns the date in the base name of a snapshot file and
+// falls back to its modification time.
+func SnapshotFileDate(filename string) (time.Time, error) {
+	if t, ok := SnapshotDate(filepath.Base(filename)); ok {
+		return t, nil
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime().UTC(), nil
+}
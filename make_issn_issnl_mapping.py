@@ -2,6 +2,9 @@
 #
 # Related: https://archive.org/details/issn_issnl_mappings
 #
+# Superseded by `issnlister mapping data.ndj`, which writes both directions and
+# reports records without ISSN-L.
+#
 # To create a ISSN ISSNL list:
 #
 #     $ issnlister -c data.ndj
@@ -0,0 +1,300 @@
+package probecache
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/store"
+)
+
+// ErrNotFound is returned for ISSN without a cached result or body. It
+// matches fs.ErrNotExist.
+var ErrNotFound = fmt.Errorf("probecache: %w", fs.ErrNotExist)
+
+// RunsFile is the name of the run log in a cache directory.
+const RunsFile = "runs.ndjson"
+
+// Run records a single issnprobe invocation.
+type Run struct {
+	ID        int       `json:"id"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	Mode      string    `json:"mode"`
+	Args      []string  `json:"args,omitempty"`
+	Seed      int64     `json:"seed,omitempty"` // RNG seed of estimate and stratified runs.
+	Probes    int       `json:"probes"`
+	Hits      int       `json:"hits"`
+	Legacy    int       `json:"legacy"`
+	Cached    int       `json:"cached"`
+	Unchanged int       `json:"unchanged,omitempty"`
+	Errors    int       `json:"errors"`
+	Requests  int       `json:"requests"`
+}
+
+// Cache stores probe results, the bodies of registered ISSN and a log of
+// runs. It is implemented by a directory (Dir) and a single database file
+// (DB).
+type Cache interface {
+	// Result returns the cached result for an ISSN or ErrNotFound.
+	Result(v string) (*Result, error)
+	PutResult(r *Result) error
+	// Body returns the saved body for an ISSN or ErrNotFound.
+	Body(v string) ([]byte, error)
+	PutBody(v string, body []byte, fetched time.Time) error
+	// Walk calls f for every result.
+	Walk(f func(r *Result) error) error
+	// Runs returns all runs, oldest first.
+	Runs() ([]Run, error)
+	// AddRun assigns an ID to a run and adds it to the log.
+	AddRun(r *Run) error
+	String() string
+	Close() error
+}
+
+// Open opens a cache, a database for a filename with a .db suffix,
+// otherwise a directory. Both are created, if necessary.
+func Open(path string) (Cache, error) {
+	if IsDB(path) {
+		return OpenDB(path)
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	return &Dir{Path: path}, nil
+}
+
+// IsDB reports whether a path names a database, rather than a directory.
+func IsDB(path string) bool {
+	return strings.HasSuffix(path, ".db")
+}
+
+// Copy copies results, bodies and runs from one cache to another, e.g. to
+// export a directory into a database or back. It returns the number of
+// results copied.
+func Copy(dst, src Cache) (n int, err error) {
+	if db, ok := dst.(*DB); ok {
+		// Sync once at the end, instead of on every write.
+		db.db.NoSync = true
+		defer func() {
+			db.db.NoSync = false
+			if serr := db.db.Sync(); err == nil {
+				err = serr
+			}
+		}()
+	}
+	err = src.Walk(func(r *Result) error {
+		if err := dst.PutResult(r); err != nil {
+			return err
+		}
+		n++
+		body, err := src.Body(r.ISSN)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return dst.PutBody(r.ISSN, body, r.FetchedAt)
+	})
+	if err != nil {
+		return n, err
+	}
+	// Runs get a new ID in dst; runs copied before are recognized by their
+	// start time, so copying twice does not repeat them.
+	have, err := dst.Runs()
+	if err != nil {
+		return n, err
+	}
+	seen := make(map[time.Time]bool)
+	for _, r := range have {
+		seen[r.Started.UTC()] = true
+	}
+	runs, err := src.Runs()
+	if err != nil {
+		return n, err
+	}
+	for i := range runs {
+		if seen[runs[i].Started.UTC()] {
+			continue
+		}
+		seen[runs[i].Started.UTC()] = true
+		if err := dst.AddRun(&runs[i]); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Dir is the cache directory layout, see Path and BodyPath. If Store is
+// set, bodies are written to the record store instead of files. Otherwise,
+// a record store in the directory is opened for reading on first use and
+// closed with the Dir.
+type Dir struct {
+	Path  string
+	Store *store.Store
+
+	mu     sync.Mutex
+	opened bool
+	reader *store.Store
+}
+
+// readStore returns the record store in the directory, opened for reading,
+// or nil, if there is none.
+func (d *Dir) readStore() *store.Store {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.opened {
+		d.opened = true
+		if s, err := store.OpenReadOnly(StorePath(d.Path)); err == nil {
+			d.reader = s
+		}
+	}
+	return d.reader
+}
+
+func (d *Dir) String() string { return d.Path }
+
+// Result reads a single result file.
+func (d *Dir) Result(v string) (*Result, error) {
+	b, err := os.ReadFile(Path(d.Path, v))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r Result
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// PutResult writes a single result file.
+func (d *Dir) PutResult(r *Result) error {
+	filename := Path(d.Path, r.ISSN)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, b, 0644)
+}
+
+// Body reads a body from the store or a file.
+func (d *Dir) Body(v string) ([]byte, error) {
+	if d.Store != nil {
+		if n, err := issn.Parse(v); err == nil {
+			if _, body, err := d.Store.Get(n); err == nil {
+				return body, nil
+			}
+		}
+	} else if s := d.readStore(); s != nil {
+		if body, err := storeBody(s, v); err == nil {
+			return body, nil
+		}
+	}
+	b, err := os.ReadFile(BodyPath(d.Path, v))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return b, err
+}
+
+// PutBody writes a body to the store or a file.
+func (d *Dir) PutBody(v string, body []byte, fetched time.Time) error {
+	if d.Store != nil {
+		n, err := issn.Parse(v)
+		if err != nil {
+			return err
+		}
+		return d.Store.Put(n, fetched, body)
+	}
+	filename := BodyPath(d.Path, v)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, body, 0644)
+}
+
+// Walk calls f for every result file.
+func (d *Dir) Walk(f func(r *Result) error) error {
+	return Walk(d.Path, f)
+}
+
+// Runs reads the run log.
+func (d *Dir) Runs() ([]Run, error) {
+	f, err := os.Open(filepath.Join(d.Path, RunsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		runs []Run
+		sc   = bufio.NewScanner(f)
+	)
+	for sc.Scan() {
+		var r Run
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			continue
+		}
+		runs = append(runs, r)
+	}
+	return runs, sc.Err()
+}
+
+// AddRun appends a run to the run log.
+func (d *Dir) AddRun(r *Run) error {
+	runs, err := d.Runs()
+	if err != nil {
+		return err
+	}
+	r.ID = 1
+	if len(runs) > 0 {
+		r.ID = runs[len(runs)-1].ID + 1
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(d.Path, RunsFile), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Close closes the stores, if any.
+func (d *Dir) Close() error {
+	var err error
+	if d.Store != nil {
+		err = d.Store.Close()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.reader != nil {
+		if cerr := d.reader.Close(); err == nil {
+			err = cerr
+		}
+		d.reader = nil
+	}
+	return err
+}
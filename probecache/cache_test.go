@@ -0,0 +1,54 @@
+package probecache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/store"
+)
+
+func TestDirBodyFromStore(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		now = time.Now()
+	)
+	w, err := store.Open(StorePath(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.Put(issn.MustParse("1932-6203"), now, []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	d := &Dir{Path: dir}
+	if b, err := d.Body("1932-6203"); err != nil || string(b) != "a" {
+		t.Fatalf("got %q %v, want a", b, err)
+	}
+	// Written while the store is open for reading.
+	if err := w.Put(issn.MustParse("0378-5955"), now, []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := d.Body("0378-5955"); err != nil || string(b) != "b" {
+		t.Errorf("appended body: got %q %v, want b", b, err)
+	}
+	if _, err := d.Body("0000-0019"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing body: got %v, want ErrNotFound", err)
+	}
+	if b, err := ReadBody(dir, "1932-6203"); err != nil || string(b) != "a" {
+		t.Errorf("ReadBody: got %q %v, want a", b, err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d.reader != nil {
+		t.Errorf("store not closed")
+	}
+}
@@ -0,0 +1,214 @@
+package probecache
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of a cache database. Results and bodies are keyed by ISSN, runs
+// by a sequence number.
+var (
+	resultsBucket = []byte("results")
+	bodiesBucket  = []byte("bodies")
+	runsBucket    = []byte("runs")
+)
+
+// DB is a cache in a single database file, which can be copied, backed up
+// and inspected as a whole. Only one process may open it for writing.
+type DB struct {
+	db       *bolt.DB
+	filename string
+}
+
+// OpenDB opens or creates a cache database.
+func OpenDB(filename string) (*DB, error) {
+	db, err := bolt.Open(filename, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{resultsBucket, bodiesBucket, runsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &DB{db: db, filename: filename}, nil
+}
+
+// OpenReadOnly opens an existing cache for reading, a database or a
+// directory. A database may be read by several processes at once, but not
+// while it is being written.
+func OpenReadOnly(path string) (Cache, error) {
+	if !IsDB(path) {
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+		return &Dir{Path: path}, nil
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return &DB{db: db, filename: path}, nil
+}
+
+func (d *DB) String() string { return d.filename }
+
+// get returns a copy of a value, as values are only valid within a
+// transaction.
+func (d *DB) get(bucket []byte, key string) ([]byte, error) {
+	var b []byte
+	err := d.db.View(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(bucket)
+		if bk == nil {
+			return ErrNotFound
+		}
+		v := bk.Get([]byte(key))
+		if v == nil {
+			return ErrNotFound
+		}
+		b = append([]byte(nil), v...)
+		return nil
+	})
+	return b, err
+}
+
+func (d *DB) put(bucket []byte, key string, value []byte) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(key), value)
+	})
+}
+
+// Result returns the result for an ISSN.
+func (d *DB) Result(v string) (*Result, error) {
+	b, err := d.get(resultsBucket, v)
+	if err != nil {
+		return nil, err
+	}
+	var r Result
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// PutResult stores a result.
+func (d *DB) PutResult(r *Result) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return d.put(resultsBucket, r.ISSN, b)
+}
+
+// Body returns the body for an ISSN.
+func (d *DB) Body(v string) ([]byte, error) {
+	return d.get(bodiesBucket, v)
+}
+
+// PutBody stores a body; the time of the fetch is kept with the result.
+func (d *DB) PutBody(v string, body []byte, fetched time.Time) error {
+	return d.put(bodiesBucket, v, body)
+}
+
+// Walk calls f for every result, in ISSN order. Results are read in
+// batches, so f may write to the database.
+func (d *DB) Walk(f func(r *Result) error) error {
+	const batchSize = 10000
+	var (
+		last []byte
+		done bool // The cursor is exhausted.
+	)
+	for !done {
+		var batch []*Result
+		err := d.db.View(func(tx *bolt.Tx) error {
+			bk := tx.Bucket(resultsBucket)
+			if bk == nil {
+				done = true
+				return nil
+			}
+			c := bk.Cursor()
+			k, v := c.First()
+			if last != nil {
+				if k, v = c.Seek(last); k != nil && string(k) == string(last) {
+					k, v = c.Next()
+				}
+			}
+			// Count entries read, not results, so invalid entries neither
+			// end the walk nor keep the transaction open.
+			for n := 0; k != nil && n < batchSize; k, v = c.Next() {
+				n++
+				last = append(last[:0], k...)
+				var r Result
+				if err := json.Unmarshal(v, &r); err != nil || len(r.ISSN) != 9 {
+					continue
+				}
+				batch = append(batch, &r)
+			}
+			done = k == nil
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, r := range batch {
+			if err := f(r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Runs returns all runs, oldest first.
+func (d *DB) Runs() ([]Run, error) {
+	var runs []Run
+	err := d.db.View(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(runsBucket)
+		if bk == nil {
+			return nil
+		}
+		return bk.ForEach(func(k, v []byte) error {
+			var r Run
+			if err := json.Unmarshal(v, &r); err == nil {
+				runs = append(runs, r)
+			}
+			return nil
+		})
+	})
+	return runs, err
+}
+
+// AddRun stores a run under the next sequence number.
+func (d *DB) AddRun(r *Run) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(runsBucket)
+		id, err := bk.NextSequence()
+		if err != nil {
+			return err
+		}
+		r.ID = int(id)
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, id)
+		return bk.Put(key, b)
+	})
+}
+
+// Close closes the database.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
@@ -0,0 +1,104 @@
+package probecache
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestWalkSkipsInvalid(t *testing.T) {
+	d, err := OpenDB(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	// More invalid entries than fit into a batch, before the only result.
+	err = d.db.Update(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(resultsBucket)
+		for i := 0; i < 25000; i++ {
+			if err := bk.Put([]byte(fmt.Sprintf("0000-%05d", i)), []byte("{}")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.PutResult(&Result{ISSN: "1932-6203", Registered: true}); err != nil {
+		t.Fatal(err)
+	}
+	var found []string
+	if err := d.Walk(func(r *Result) error {
+		found = append(found, r.ISSN)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0] != "1932-6203" {
+		t.Errorf("got %v, want [1932-6203]", found)
+	}
+}
+
+func TestCopyRunsOnce(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		started = time.Date(2026, 4, 19, 10, 0, 0, 0, time.UTC)
+	)
+	src, err := Open(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := src.AddRun(&Run{Started: started.Add(time.Duration(i) * time.Hour), Mode: "random"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dst, err := Open(filepath.Join(dir, "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := Copy(dst, src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs, err := dst.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Errorf("got %d runs, want 2", len(runs))
+	}
+}
+
+func TestCopyKeepsRun(t *testing.T) {
+	dir := t.TempDir()
+	src, err := Open(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := OpenDB(filepath.Join(dir, "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	started := time.Date(2026, 4, 19, 10, 0, 0, 0, time.UTC)
+	if err := src.PutResult(&Result{ISSN: "1932-6203", Registered: true, Run: started, Seed: 42}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Copy(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	r, err := dst.Result("1932-6203")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Run.Equal(started) || r.Seed != 42 {
+		t.Errorf("got run %v seed %d, want %v 42", r.Run, r.Seed, started)
+	}
+}
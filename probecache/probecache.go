@@ -0,0 +1,124 @@
+// Package probecache reads the per-ISSN cache written by issnprobe, which
+// is laid out as <dir>/<prefix4>/<issn>.json, with an optional JSON-LD body
+// saved next to it as <issn>.jsonld or in a record store <dir>/bodies.store.
+package probecache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/miku/issnlister/issn"
+	"github.com/miku/issnlister/store"
+)
+
+// StoreFile is the name of the record store for bodies in a cache
+// directory, written by issnprobe -store.
+const StoreFile = "bodies.store"
+
+// Result is the per-ISSN cache record. FetchedAt is the time the response
+// was last downloaded, CheckedAt the time of the last request, including
+// conditional requests answered with 304 Not Modified. ETag, LastModified
+// and BodyHash describe the body of a 200 response. Run is the start time
+// of the run, that fetched the response, which identifies the run across
+// caches, and Seed its random seed, if any.
+type Result struct {
+	ISSN          string    `json:"issn"`
+	Status        int       `json:"status"`
+	Registered    bool      `json:"registered"`
+	Legacy        bool      `json:"legacy,omitempty"`
+	SchemaVersion int       `json:"schema_version"`
+	FetchedAt     time.Time `json:"fetched_at"`
+	CheckedAt     time.Time `json:"checked_at,omitzero"`
+	ETag          string    `json:"etag,omitempty"`
+	LastModified  string    `json:"last_modified,omitempty"`
+	BodyHash      string    `json:"body_hash,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	Run           time.Time `json:"run,omitzero"`
+	Seed          int64     `json:"seed,omitempty"`
+}
+
+// Checked returns the time of the last request, which is the fetch time for
+// results cached before conditional requests.
+func (r *Result) Checked() time.Time {
+	if r.CheckedAt.IsZero() {
+		return r.FetchedAt
+	}
+	return r.CheckedAt
+}
+
+// BodyHash returns the hash of a body as hex, a truncated SHA-256 like the
+// hashes in a record store.
+func BodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:16])
+}
+
+// Path returns the location of the cached result for an ISSN.
+func Path(dir, issn string) string {
+	return filepath.Join(dir, issn[:4], issn+".json")
+}
+
+// BodyPath returns the location of the saved JSON-LD body for an ISSN.
+func BodyPath(dir, issn string) string {
+	return filepath.Join(dir, issn[:4], issn+".jsonld")
+}
+
+// StorePath returns the location of the record store for bodies.
+func StorePath(dir string) string {
+	return filepath.Join(dir, StoreFile)
+}
+
+// storeBody returns the body for an ISSN from a record store, reading
+// blocks appended by a concurrent writer, if necessary.
+func storeBody(s *store.Store, v string) ([]byte, error) {
+	n, err := issn.Parse(v)
+	if err != nil {
+		return nil, err
+	}
+	_, body, err := s.Get(n)
+	if err == store.ErrNotFound && s.Refresh() == nil {
+		_, body, err = s.Get(n)
+	}
+	return body, err
+}
+
+// ReadBody returns the saved JSON-LD body for an ISSN, from the record
+// store, if there is one, or from a file. The store is opened for a single
+// lookup, Dir.Body keeps it open.
+func ReadBody(dir, v string) ([]byte, error) {
+	if s, err := store.OpenReadOnly(StorePath(dir)); err == nil {
+		body, err := storeBody(s, v)
+		s.Close()
+		if err == nil {
+			return body, nil
+		}
+	}
+	return os.ReadFile(BodyPath(dir, v))
+}
+
+// Walk calls f for every result in the cache directory. Files, that cannot
+// be decoded are skipped.
+func Walk(dir string, f func(r *Result) error) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var r Result
+		if err := json.Unmarshal(b, &r); err != nil || len(r.ISSN) != 9 {
+			return nil
+		}
+		return f(&r)
+	})
+}
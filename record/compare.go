@@ -0,0 +1,101 @@
+package record
+
+import (
+	"slices"
+	"sort"
+)
+
+// Kinds of field changes.
+const (
+	FieldAdded    = "field-added"
+	FieldRemoved  = "field-removed"
+	FieldModified = "field-modified"
+)
+
+// FieldChange is a difference in a single field between two versions of a
+// record. Fields are named by their JSON key, e.g. key_title. Lists are
+// compared as sets, Old and New are sorted.
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new,omitempty"`
+}
+
+// Kind returns whether the field has been added, removed or modified.
+func (c FieldChange) Kind() string {
+	switch {
+	case c.Old == nil:
+		return FieldAdded
+	case c.New == nil:
+		return FieldRemoved
+	}
+	return FieldModified
+}
+
+// Compare returns the fields, that differ between an older and a newer
+// version of a record, in the order of the Record fields.
+func Compare(older, newer *Record) []FieldChange {
+	var changes []FieldChange
+	str := func(field, a, b string) {
+		if a == b {
+			return
+		}
+		c := FieldChange{Field: field}
+		if a != "" {
+			c.Old = a
+		}
+		if b != "" {
+			c.New = b
+		}
+		changes = append(changes, c)
+	}
+	list := func(field string, a, b []string) {
+		a, b = sortedCopy(a), sortedCopy(b)
+		if slices.Equal(a, b) {
+			return
+		}
+		c := FieldChange{Field: field}
+		if len(a) > 0 {
+			c.Old = a
+		}
+		if len(b) > 0 {
+			c.New = b
+		}
+		changes = append(changes, c)
+	}
+	str("issn", older.ISSN, newer.ISSN)
+	str("issnl", older.ISSNL, newer.ISSNL)
+	str("key_title", older.KeyTitle, newer.KeyTitle)
+	str("main_title", older.MainTitle, newer.MainTitle)
+	list("other_titles", older.OtherTitles, newer.OtherTitles)
+	str("country", older.Country, newer.Country)
+	str("country_name", older.CountryName, newer.CountryName)
+	str("medium", older.Medium, newer.Medium)
+	str("publisher", older.Publisher, newer.Publisher)
+	list("urls", older.URLs, newer.URLs)
+	str("status", older.Status, newer.Status)
+	str("record_status", older.RecordStatus, newer.RecordStatus)
+	str("created", older.Created, newer.Created)
+	str("modified", older.Modified, newer.Modified)
+	list("related", relations(older.Related), relations(newer.Related))
+	return changes
+}
+
+// relations formats relations as "type issn", for comparison.
+func relations(rs []Relation) []string {
+	var result []string
+	for _, r := range rs {
+		result = append(result, r.Type+" "+r.ISSN)
+	}
+	return result
+}
+
+// sortedCopy returns the distinct values of a list, sorted.
+func sortedCopy(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	c := append([]string(nil), s...)
+	sort.Strings(c)
+	return slices.Compact(c)
+}
@@ -0,0 +1,485 @@
+// Package record decodes the JSON-LD documents served by the ISSN portal into
+// a typed Record. It works with a single line of a harvest written by
+// issnlister -m as well as with a body saved by issnprobe.
+//
+// A document is a graph of nodes, linked by "@id". A typical record for
+// 2257-6754 contains a main node "resource/ISSN/2257-6754", some fragment
+// nodes like "resource/ISSN/2257-6754#KeyTitle" or "...#Record", a link
+// node "resource/ISSN-L/2257-6754" and auxiliary nodes for countries or
+// organizations. Keys may be compact ("mainTitle") or full IRIs
+// ("http://schema.org/name"), values may be literals, {"@value": ...},
+// {"@id": ...} or lists of those.
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miku/issnlister/issn"
+)
+
+var (
+	// ErrNoGraph is returned, if a document does not contain any nodes.
+	ErrNoGraph = errors.New("record: no nodes in document")
+	// ErrNoISSN is returned, if no ISSN could be found in a document.
+	ErrNoISSN = errors.New("record: no issn in document")
+)
+
+// Record is a typed view of a single ISSN record.
+type Record struct {
+	ISSN         string     `json:"issn"`
+	ISSNL        string     `json:"issnl,omitempty"`
+	KeyTitle     string     `json:"key_title,omitempty"`
+	MainTitle    string     `json:"main_title,omitempty"`
+	OtherTitles  []string   `json:"other_titles,omitempty"`
+	Country      string     `json:"country,omitempty"`      // Country code, e.g. FRA.
+	CountryName  string     `json:"country_name,omitempty"` // e.g. France.
+	Medium       string     `json:"medium,omitempty"`       // e.g. Print, Online.
+	Publisher    string     `json:"publisher,omitempty"`
+	URLs         []string   `json:"urls,omitempty"`
+	Status       string     `json:"status,omitempty"`        // Identifier status, e.g. Valid, Cancelled.
+	RecordStatus string     `json:"record_status,omitempty"` // e.g. Register, Provisional.
+	Created      string     `json:"created,omitempty"`       // Record creation date, as found.
+	Modified     string     `json:"modified,omitempty"`      // Record modification date, as found.
+	Related      []Relation `json:"related,omitempty"`
+}
+
+// Relation links a record to another ISSN, e.g. a different medium version
+// or a predecessor.
+type Relation struct {
+	Type string `json:"type"` // Property name, e.g. otherPhysicalFormat.
+	ISSN string `json:"issn"`
+}
+
+// dateLayouts are the layouts tried by ParseDate.
+var dateLayouts = []string{
+	"20060102150405.0",
+	"20060102150405",
+	"20060102",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// ParseDate parses the record dates found in portal records, e.g.
+// "20160218133127.0" or "2016-02-18".
+func ParseDate(s string) (time.Time, error) {
+	var err error
+	for _, layout := range dateLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// ModifiedTime returns the parsed modification date, or the creation date, if
+// the record was never modified.
+func (r *Record) ModifiedTime() (time.Time, error) {
+	if r.Modified != "" {
+		return ParseDate(r.Modified)
+	}
+	return ParseDate(r.Created)
+}
+
+// Decode decodes a single JSON-LD document.
+func Decode(b []byte) (*Record, error) {
+	return DecodeISSN(b, "")
+}
+
+// DecodeISSN decodes a single JSON-LD document requested for a given ISSN.
+// Documents include nodes of related ISSN; the main node is the one the
+// #Record node points to, or else the one of the requested ISSN.
+func DecodeISSN(b []byte, want string) (*Record, error) {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	if want != "" {
+		if w, err := issn.Normalize(want); err == nil {
+			want = w
+		}
+	}
+	return fromGraph(newGraph(v), want)
+}
+
+// LineError is returned by Decoder for a line, that could not be decoded.
+type LineError struct {
+	Line int // Line number, starting at 1.
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("record: line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error { return e.Err }
+
+// Decoder reads records from newline delimited JSON, e.g. a harvest file.
+type Decoder struct {
+	br   *bufio.Reader
+	line int
+}
+
+// NewDecoder returns a decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{br: bufio.NewReader(r)}
+}
+
+// Decode returns the next record, and io.EOF at the end of the input. A
+// partial last line is reported as io.ErrUnexpectedEOF. Lines, that cannot
+// be decoded are reported as *LineError; the decoder may be used further
+// after such errors. Empty lines are skipped.
+func (d *Decoder) Decode() (*Record, error) {
+	for {
+		line, err := d.br.ReadBytes('\n')
+		if err == io.EOF {
+			if len(strings.TrimSpace(string(line))) > 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		d.line++
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		r, err := Decode(line)
+		if err != nil {
+			return nil, &LineError{Line: d.line, Err: err}
+		}
+		return r, nil
+	}
+}
+
+// node is a single JSON-LD node, with normalized keys.
+type node map[string][]interface{}
+
+// graph maps normalized "@id" values to nodes.
+type graph map[string]node
+
+// newGraph collects all nodes from a decoded document, which may be a
+// single node, a list of nodes or an object with a "@graph" key.
+func newGraph(v interface{}) graph {
+	g := make(graph)
+	var add func(v interface{})
+	add = func(v interface{}) {
+		switch t := v.(type) {
+		case []interface{}:
+			for _, u := range t {
+				add(u)
+			}
+		case map[string]interface{}:
+			if sub, ok := t["@graph"]; ok {
+				add(sub)
+				return
+			}
+			id, _ := t["@id"].(string)
+			id = normalizeID(id)
+			n, ok := g[id]
+			if !ok {
+				n = make(node)
+				g[id] = n
+			}
+			for k, u := range t {
+				if k == "@id" || k == "@context" {
+					continue
+				}
+				k = normalizeKey(k)
+				if l, ok := u.([]interface{}); ok {
+					n[k] = append(n[k], l...)
+				} else {
+					n[k] = append(n[k], u)
+				}
+			}
+		}
+	}
+	add(v)
+	return g
+}
+
+// normalizeID strips the portal host from an id, so ids look like
+// "resource/ISSN/2257-6754" regardless of the serialization.
+func normalizeID(id string) string {
+	for _, p := range []string{"https://portal.issn.org/", "http://portal.issn.org/"} {
+		if strings.HasPrefix(id, p) {
+			return strings.TrimPrefix(id, p)
+		}
+	}
+	return id
+}
+
+// normalizeKey turns IRIs and compact IRIs into plain property names, e.g.
+// "http://schema.org/name" and "schema:name" both become "name".
+func normalizeKey(k string) string {
+	if strings.HasPrefix(k, "@") {
+		return k
+	}
+	if i := strings.LastIndexAny(k, "/#:"); i >= 0 && i < len(k)-1 {
+		return k[i+1:]
+	}
+	return k
+}
+
+// literals returns the literal (non-reference) string values of a property.
+func (n node) literals(key string) (result []string) {
+	for _, v := range n[key] {
+		switch t := v.(type) {
+		case string:
+			result = append(result, t)
+		case map[string]interface{}:
+			if s, ok := t["@value"].(string); ok {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+// refs returns the referenced ids of a property. Plain strings are
+// considered references as well, since compacted documents use them for
+// both.
+func (n node) refs(key string) (result []string) {
+	for _, v := range n[key] {
+		switch t := v.(type) {
+		case string:
+			result = append(result, normalizeID(t))
+		case map[string]interface{}:
+			if s, ok := t["@id"].(string); ok {
+				result = append(result, normalizeID(s))
+			}
+		}
+	}
+	return result
+}
+
+// first returns the first literal value of the first property found.
+func (n node) first(keys ...string) string {
+	for _, k := range keys {
+		if l := n.literals(k); len(l) > 0 {
+			return strings.TrimSpace(l[0])
+		}
+	}
+	return ""
+}
+
+// fragment returns the part after # or the last path segment, e.g. "Valid"
+// for "vocabularies/IdentifierStatus#Valid".
+func fragment(s string) string {
+	if i := strings.LastIndexAny(s, "#/"); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+// issnFromID returns the ISSN of ids like resource/ISSN/1234-5679#KeyTitle.
+func issnFromID(id, prefix string) string {
+	if !strings.HasPrefix(id, prefix) {
+		return ""
+	}
+	s := strings.TrimPrefix(id, prefix)
+	if i := strings.Index(s, "#"); i >= 0 {
+		s = s[:i]
+	}
+	v, err := issn.Normalize(s)
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+// mainID returns the id of the main node of a graph: the entity of a #Record
+// node, the node of the wanted ISSN, or the first node of an ISSN with a
+// title, in id order. It returns the empty string, if there is no ISSN node.
+func mainID(g graph, ids []string, want string) string {
+	isMain := func(id string) bool {
+		_, ok := g[id]
+		return ok && !strings.Contains(id, "#") && issnFromID(id, "resource/ISSN/") != ""
+	}
+	// The record of the wanted ISSN first, any other record else.
+	records := make([]string, 0, 1)
+	if want != "" {
+		records = append(records, "resource/ISSN/"+want+"#Record")
+	}
+	for _, id := range ids {
+		if strings.HasSuffix(id, "#Record") {
+			records = append(records, id)
+		}
+	}
+	for _, id := range records {
+		for _, ref := range g[id].refs("mainEntity") {
+			if isMain(ref) {
+				return ref
+			}
+		}
+	}
+	if want != "" && isMain("resource/ISSN/"+want) {
+		return "resource/ISSN/" + want
+	}
+	var first string
+	for _, id := range ids {
+		if !isMain(id) {
+			continue
+		}
+		if g[id].first("mainTitle", "name") != "" {
+			return id
+		}
+		if first == "" {
+			first = id
+		}
+	}
+	return first
+}
+
+// properties, which are not considered relations to other ISSN.
+var ignoredRelations = map[string]bool{
+	"@type":        true,
+	"identifiedBy": true,
+	"isPartOf":     true,
+	"mainEntity":   true,
+	"title":        true,
+	"publication":  true,
+	"value":        true,
+}
+
+func fromGraph(g graph, want string) (*Record, error) {
+	if len(g) == 0 {
+		return nil, ErrNoGraph
+	}
+	r := &Record{}
+	ids := make([]string, 0, len(g))
+	for id := range g {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var main node
+	if id := mainID(g, ids, want); id != "" {
+		r.ISSN, main = issnFromID(id, "resource/ISSN/"), g[id]
+	}
+	if main == nil {
+		main = make(node)
+		for _, id := range ids {
+			if v := issnFromID(id, "resource/ISSN/"); v != "" {
+				r.ISSN = v
+				break
+			}
+		}
+	}
+	if r.ISSN == "" {
+		return nil, ErrNoISSN
+	}
+	self := "resource/ISSN/" + r.ISSN
+	// ISSN-L, from the isPartOf link of the main node or its #ISSN-L value;
+	// only else from a link node, which may belong to a related record.
+	for _, ref := range main.refs("isPartOf") {
+		if v := issnFromID(ref, "resource/ISSN-L/"); v != "" {
+			r.ISSNL = v
+			break
+		}
+	}
+	if r.ISSNL == "" {
+		if v, err := issn.Normalize(g[self+"#ISSN-L"].first("value")); err == nil {
+			r.ISSNL = v
+		}
+	}
+	if r.ISSNL == "" {
+		for _, id := range ids {
+			if v := issnFromID(id, "resource/ISSN-L/"); v != "" {
+				r.ISSNL = v
+				break
+			}
+		}
+	}
+	// Titles.
+	r.KeyTitle = g[self+"#KeyTitle"].first("value")
+	if r.KeyTitle == "" {
+		r.KeyTitle = main.first("keyTitle")
+	}
+	r.MainTitle = main.first("mainTitle")
+	seen := map[string]bool{r.KeyTitle: true, r.MainTitle: true, "": true}
+	for _, k := range []string{"name", "alternateName", "otherTitle", "title"} {
+		for _, t := range main.literals(k) {
+			t = strings.TrimSpace(t)
+			// References to fragment nodes look like literals.
+			if seen[t] || strings.HasPrefix(t, "resource/") {
+				continue
+			}
+			seen[t] = true
+			r.OtherTitles = append(r.OtherTitles, t)
+		}
+	}
+	if r.MainTitle == "" && len(r.OtherTitles) > 0 {
+		r.MainTitle, r.OtherTitles = r.OtherTitles[0], r.OtherTitles[1:]
+	}
+	// Country and publisher, usually found on the publication event.
+	event := g[self+"#ReferencePublicationEvent"]
+	for _, n := range []node{event, main} {
+		for _, ref := range n.refs("location") {
+			if strings.HasPrefix(ref, "countries/") {
+				r.Country = fragment(ref)
+				r.CountryName = g[ref].first("label", "name")
+				break
+			}
+		}
+		if r.Country != "" {
+			break
+		}
+	}
+	for _, n := range []node{main, event} {
+		for _, k := range []string{"publisher", "publishedBy"} {
+			for _, ref := range n.refs(k) {
+				if o, ok := g[ref]; ok {
+					r.Publisher = o.first("name", "label")
+				} else {
+					r.Publisher = strings.TrimSpace(ref)
+				}
+				if r.Publisher != "" {
+					break
+				}
+			}
+		}
+		if r.Publisher != "" {
+			break
+		}
+	}
+	if refs := main.refs("format"); len(refs) > 0 {
+		r.Medium = fragment(refs[0])
+	}
+	r.URLs = main.literals("url")
+	// Status of the identifier and the record, with dates.
+	if s := g[self+"#ISSN"].first("status"); s != "" {
+		r.Status = fragment(s)
+	}
+	rec := g[self+"#Record"]
+	if s := rec.first("status"); s != "" {
+		r.RecordStatus = fragment(s)
+	}
+	r.Created = rec.first("dateCreated", "created")
+	r.Modified = rec.first("modified", "dateModified")
+	// Related ISSN, any reference from the main node to another ISSN.
+	keys := make([]string, 0, len(main))
+	for k := range main {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if ignoredRelations[k] {
+			continue
+		}
+		for _, ref := range main.refs(k) {
+			if v := issnFromID(ref, "resource/ISSN/"); v != "" && v != r.ISSN {
+				r.Related = append(r.Related, Relation{Type: k, ISSN: v})
+			}
+		}
+	}
+	return r, nil
+}
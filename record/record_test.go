@@ -0,0 +1,91 @@
+package record
+
+import "testing"
+
+// relatedDoc is a record for 2257-6754 with a node for its online version
+// 2257-6770, which sorts after the main node and has a title, too.
+const relatedDoc = `{"@context": {}, "@graph": [
+	{"@id": "resource/ISSN/2257-6754", "mainTitle": "Revue imprimée", "otherPhysicalFormat": "resource/ISSN/2257-6770", "isPartOf": "resource/ISSN-L/2257-6754"},
+	{"@id": "resource/ISSN/2257-6754#KeyTitle", "value": "Revue (Print)"},
+	{"@id": "resource/ISSN/2257-6754#Record", "mainEntity": "resource/ISSN/2257-6754", "status": "vocabularies/RecordStatus#Register", "modified": "20160218133127.0"},
+	{"@id": "resource/ISSN/2257-6770", "mainTitle": "Revue en ligne", "name": "Revue en ligne"},
+	{"@id": "resource/ISSN-L/2257-6754"}
+]}`
+
+func TestDecodeMainNode(t *testing.T) {
+	var cases = []struct {
+		about string
+		doc   string
+		want  string // Requested ISSN.
+		issn  string
+		title string
+	}{
+		{"record entity", relatedDoc, "", "2257-6754", "Revue imprimée"},
+		{"record entity wins over request", relatedDoc, "2257-6770", "2257-6754", "Revue imprimée"},
+		{"requested, no record node", `{"@graph": [
+			{"@id": "resource/ISSN/2257-6754", "mainTitle": "Revue imprimée"},
+			{"@id": "resource/ISSN/2257-6770", "mainTitle": "Revue en ligne"}]}`,
+			"2257-6770", "2257-6770", "Revue en ligne"},
+		{"first titled", `{"@graph": [
+			{"@id": "resource/ISSN/2257-6754"},
+			{"@id": "resource/ISSN/2257-6770", "mainTitle": "Revue en ligne"},
+			{"@id": "resource/ISSN/2257-6789", "mainTitle": "Autre revue"}]}`,
+			"", "2257-6770", "Revue en ligne"},
+	}
+	for _, c := range cases {
+		r, err := DecodeISSN([]byte(c.doc), c.want)
+		if err != nil {
+			t.Fatalf("%s: %v", c.about, err)
+		}
+		if r.ISSN != c.issn || r.MainTitle != c.title {
+			t.Errorf("%s: got %s %q, want %s %q", c.about, r.ISSN, r.MainTitle, c.issn, c.title)
+		}
+	}
+	r, err := Decode([]byte(relatedDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ISSNL != "2257-6754" || r.KeyTitle != "Revue (Print)" || r.RecordStatus != "Register" {
+		t.Errorf("got %+v", r)
+	}
+	if len(r.Related) != 1 || r.Related[0] != (Relation{Type: "otherPhysicalFormat", ISSN: "2257-6770"}) {
+		t.Errorf("related: got %v", r.Related)
+	}
+}
+
+func TestDecodeISSNL(t *testing.T) {
+	var cases = []struct {
+		about string
+		doc   string
+		want  string
+	}{
+		{"isPartOf wins over a related link node", `{"@graph": [
+			{"@id": "resource/ISSN-L/0000-0019"},
+			{"@id": "resource/ISSN-L/2257-6754"},
+			{"@id": "resource/ISSN/2257-6754", "mainTitle": "T", "isPartOf": "resource/ISSN-L/2257-6754"}]}`, "2257-6754"},
+		{"#ISSN-L value", `{"@graph": [
+			{"@id": "resource/ISSN-L/0000-0019"},
+			{"@id": "resource/ISSN/2257-6754", "mainTitle": "T"},
+			{"@id": "resource/ISSN/2257-6754#ISSN-L", "value": "2257-6754"}]}`, "2257-6754"},
+		{"link node only", `{"@graph": [
+			{"@id": "resource/ISSN-L/2257-6754"},
+			{"@id": "resource/ISSN/2257-6754", "mainTitle": "T"}]}`, "2257-6754"},
+	}
+	for _, c := range cases {
+		r, err := Decode([]byte(c.doc))
+		if err != nil {
+			t.Fatalf("%s: %v", c.about, err)
+		}
+		if r.ISSNL != c.want {
+			t.Errorf("%s: got %q, want %q", c.about, r.ISSNL, c.want)
+		}
+	}
+}
+
+func TestCompareListsAsSets(t *testing.T) {
+	a := &Record{ISSN: "2257-6754", OtherTitles: []string{"B", "A"}}
+	b := &Record{ISSN: "2257-6754", OtherTitles: []string{"A", "B", "A"}}
+	if cs := Compare(a, b); len(cs) != 0 {
+		t.Errorf("got %v, want no changes", cs)
+	}
+}
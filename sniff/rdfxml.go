@@ -0,0 +1,221 @@
+package sniff
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	rdfNS = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xmlNS = "http://www.w3.org/XML/1998/namespace"
+)
+
+// ErrNotRDF is returned, if a document cannot be converted.
+var ErrNotRDF = errors.New("sniff: not an RDF/XML document")
+
+// element is a minimal DOM node.
+type element struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	children []*element
+	text     strings.Builder
+}
+
+func (e *element) attr(space, local string) (string, bool) {
+	for _, a := range e.attrs {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// parseXML reads a document into a tree and returns the root element.
+func parseXML(body []byte) (*element, error) {
+	var (
+		dec   = xml.NewDecoder(bytes.NewReader(body))
+		stack []*element
+		root  *element
+	)
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			e := &element{name: t.Name, attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, e)
+			} else if root == nil {
+				root = e
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, ErrNotRDF
+	}
+	return root, nil
+}
+
+// converter turns RDF/XML node elements into JSON-LD nodes.
+type converter struct {
+	nodes  []map[string]interface{}
+	byID   map[string]map[string]interface{}
+	blanks int
+}
+
+// node returns the JSON-LD node for an id, creating it, if necessary.
+func (c *converter) node(id string) map[string]interface{} {
+	if n, ok := c.byID[id]; ok {
+		return n
+	}
+	n := map[string]interface{}{"@id": id}
+	c.byID[id] = n
+	c.nodes = append(c.nodes, n)
+	return n
+}
+
+// add appends a value to a property, turning it into a list, if needed.
+func add(n map[string]interface{}, key string, v interface{}) {
+	switch prev := n[key].(type) {
+	case nil:
+		n[key] = v
+	case []interface{}:
+		n[key] = append(prev, v)
+	default:
+		n[key] = []interface{}{prev, v}
+	}
+}
+
+// nodeElement converts a node element and returns its id.
+func (c *converter) nodeElement(e *element) string {
+	id, ok := e.attr(rdfNS, "about")
+	if !ok {
+		if v, ok := e.attr(rdfNS, "nodeID"); ok {
+			id = "_:" + v
+		} else {
+			c.blanks++
+			id = fmt.Sprintf("_:b%d", c.blanks)
+		}
+	}
+	n := c.node(id)
+	if !(e.name.Space == rdfNS && e.name.Local == "Description") {
+		add(n, "@type", e.name.Space+e.name.Local)
+	}
+	// Property attributes.
+	for _, a := range e.attrs {
+		if a.Name.Space == rdfNS || a.Name.Space == xmlNS || a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+			continue
+		}
+		add(n, a.Name.Local, a.Value)
+	}
+	for _, p := range e.children {
+		c.propertyElement(n, p)
+	}
+	return id
+}
+
+// propertyElement converts a single property of a node.
+func (c *converter) propertyElement(n map[string]interface{}, p *element) {
+	key := p.name.Local
+	if p.name.Space == rdfNS && key == "type" {
+		key = "@type"
+	}
+	if v, ok := p.attr(rdfNS, "resource"); ok {
+		if key == "@type" {
+			add(n, key, v)
+		} else {
+			add(n, key, map[string]interface{}{"@id": v})
+		}
+		return
+	}
+	if v, ok := p.attr(rdfNS, "nodeID"); ok {
+		add(n, key, map[string]interface{}{"@id": "_:" + v})
+		return
+	}
+	if pt, _ := p.attr(rdfNS, "parseType"); pt == "Resource" {
+		c.blanks++
+		id := fmt.Sprintf("_:b%d", c.blanks)
+		sub := c.node(id)
+		for _, q := range p.children {
+			c.propertyElement(sub, q)
+		}
+		add(n, key, map[string]interface{}{"@id": id})
+		return
+	}
+	if len(p.children) > 0 {
+		for _, child := range p.children {
+			add(n, key, map[string]interface{}{"@id": c.nodeElement(child)})
+		}
+		return
+	}
+	text := strings.TrimSpace(p.text.String())
+	if lang, ok := p.attr(xmlNS, "lang"); ok && lang != "" {
+		add(n, key, map[string]interface{}{"@value": text, "@language": lang})
+		return
+	}
+	add(n, key, text)
+}
+
+// ToJSONLD converts an RDF/XML document into a JSON-LD document with a
+// "@graph" of nodes, using plain property names as keys, like the compact
+// JSON-LD served by the portal.
+func ToJSONLD(body []byte) ([]byte, error) {
+	root, err := parseXML(body)
+	if err != nil {
+		return nil, err
+	}
+	c := &converter{byID: make(map[string]map[string]interface{})}
+	if root.name.Space == rdfNS && root.name.Local == "RDF" {
+		for _, e := range root.children {
+			c.nodeElement(e)
+		}
+	} else if _, ok := root.attr(rdfNS, "about"); ok {
+		c.nodeElement(root)
+	} else {
+		return nil, ErrNotRDF
+	}
+	if len(c.nodes) == 0 {
+		return nil, ErrNotRDF
+	}
+	doc := map[string]interface{}{
+		"@context": map[string]interface{}{},
+		"@graph":   c.nodes,
+	}
+	return json.Marshal(doc)
+}
+
+// Normalize returns a JSON-LD body for a response, converting alternate
+// serializations. The returned kind is the kind of the original body. Bodies,
+// which cannot be turned into JSON-LD are returned unchanged.
+func Normalize(body []byte) ([]byte, Kind, error) {
+	k := Detect(body)
+	if k != RDFXML {
+		return body, k, nil
+	}
+	b, err := ToJSONLD(body)
+	if err != nil {
+		return body, k, err
+	}
+	return b, k, nil
+}
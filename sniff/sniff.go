@@ -0,0 +1,139 @@
+// Package sniff tells apart the kinds of responses the ISSN portal returns
+// for a JSON request: JSON-LD, RDF/XML (which happens, rarely, even if JSON
+// was requested), HTML "No data available" stubs and error pages. RDF/XML
+// can be converted into an equivalent JSON-LD document.
+package sniff
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Kind is the kind of a response body.
+type Kind int
+
+const (
+	Empty    Kind = iota // No body at all.
+	JSONLD               // JSON-LD, what we asked for.
+	JSON                 // JSON, but not JSON-LD.
+	RDFXML               // RDF/XML, an alternate serialization.
+	HTMLStub             // HTML page saying "No data available".
+	HTML                 // Any other HTML, usually an error page.
+	XML                  // XML, but not RDF.
+	Unknown              // Anything else.
+)
+
+var kindNames = [...]string{
+	Empty:    "empty",
+	JSONLD:   "jsonld",
+	JSON:     "json",
+	RDFXML:   "rdfxml",
+	HTMLStub: "htmlstub",
+	HTML:     "html",
+	XML:      "xml",
+	Unknown:  "unknown",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return fmt.Sprintf("kind(%d)", int(k))
+	}
+	return kindNames[k]
+}
+
+// Anomaly returns true for every kind, except JSON-LD.
+func (k Kind) Anomaly() bool { return k != JSONLD }
+
+// Detect looks at the start of a body and returns its kind. A body, that is
+// only whitespace or a byte order mark, is empty. A plain "No data
+// available" message counts as a stub, like the HTML page.
+func Detect(body []byte) Kind {
+	b := bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))
+	if len(b) == 0 {
+		return Empty
+	}
+	head := b
+	if len(head) > 2048 {
+		head = head[:2048]
+	}
+	lower := bytes.ToLower(head)
+	switch {
+	case b[0] == '{' || b[0] == '[':
+		if bytes.Contains(b, []byte(`"@context"`)) || bytes.Contains(b, []byte(`"@graph"`)) {
+			return JSONLD
+		}
+		return JSON
+	case bytes.Contains(lower, []byte("<!doctype html")) || bytes.Contains(lower, []byte("<html")):
+		if bytes.Contains(b, []byte("No data available")) {
+			return HTMLStub
+		}
+		return HTML
+	case b[0] == '<':
+		if bytes.Contains(head, []byte("http://www.w3.org/1999/02/22-rdf-syntax-ns#")) {
+			return RDFXML
+		}
+		return XML
+	case bytes.Contains(head, []byte("No data available")):
+		return HTMLStub
+	}
+	return Unknown
+}
+
+// Counter counts kinds of responses. It is safe for concurrent use. The zero
+// value is ready to use.
+type Counter struct {
+	mu     sync.Mutex
+	counts map[Kind]int
+}
+
+// Add counts a kind.
+func (c *Counter) Add(k Kind) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[Kind]int)
+	}
+	c.counts[k]++
+}
+
+// Counts returns a copy of the counts by kind name.
+func (c *Counter) Counts() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := make(map[string]int, len(c.counts))
+	for k, v := range c.counts {
+		m[k.String()] = v
+	}
+	return m
+}
+
+// Anomalies returns the total number of non JSON-LD responses.
+func (c *Counter) Anomalies() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int
+	for k, v := range c.counts {
+		if k.Anomaly() {
+			n += v
+		}
+	}
+	return n
+}
+
+// String returns the counts as "kind=n" pairs, sorted by kind.
+func (c *Counter) String() string {
+	m := c.Counts()
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", k, m[k])
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,91 @@
+package sniff
+
+import (
+	"testing"
+
+	"github.com/miku/issnlister/record"
+)
+
+func TestDetect(t *testing.T) {
+	var cases = []struct {
+		about string
+		body  string
+		want  Kind
+	}{
+		{"empty", "", Empty},
+		{"whitespace", " \n\t", Empty},
+		{"bom only", "\xef\xbb\xbf", Empty},
+		{"bom and whitespace", "\xef\xbb\xbf \n", Empty},
+		{"jsonld", `{"@context": {}, "@graph": []}`, JSONLD},
+		{"jsonld with bom", "\xef\xbb\xbf" + `{"@graph": []}`, JSONLD},
+		{"json", `{"a": 1}`, JSON},
+		{"html", "<!DOCTYPE html><html><body>Error</body></html>", HTML},
+		{"html stub", "<html><body>No data available</body></html>", HTMLStub},
+		{"plain stub", "No data available", HTMLStub},
+		{"rdfxml", `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"></rdf:RDF>`, RDFXML},
+		{"xml", `<?xml version="1.0"?><a/>`, XML},
+		{"text", "hello", Unknown},
+	}
+	for _, c := range cases {
+		if got := Detect([]byte(c.body)); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.about, got, c.want)
+		}
+	}
+}
+
+// rdfRecord is a record as served in RDF/XML, with a node of a related
+// ISSN and its ISSN-L.
+const rdfRecord = `<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+  xmlns:schema="http://schema.org/"
+  xmlns:bf="http://id.loc.gov/ontologies/bibframe/">
+  <schema:Periodical rdf:about="https://portal.issn.org/resource/ISSN/2257-6754">
+    <bf:mainTitle>Revue imprimée</bf:mainTitle>
+    <schema:name xml:lang="fr">Revue imprimée</schema:name>
+    <schema:isPartOf rdf:resource="https://portal.issn.org/resource/ISSN-L/2257-6754"/>
+    <bf:otherPhysicalFormat rdf:resource="https://portal.issn.org/resource/ISSN/2257-6770"/>
+  </schema:Periodical>
+  <rdf:Description rdf:about="https://portal.issn.org/resource/ISSN/2257-6754#KeyTitle">
+    <rdf:value>Revue (Print)</rdf:value>
+  </rdf:Description>
+  <rdf:Description rdf:about="https://portal.issn.org/resource/ISSN/2257-6754#Record">
+    <schema:mainEntity rdf:resource="https://portal.issn.org/resource/ISSN/2257-6754"/>
+    <bf:status rdf:resource="http://issn.org/vocabularies/RecordStatus#Register"/>
+  </rdf:Description>
+  <rdf:Description rdf:about="https://portal.issn.org/resource/ISSN/2257-6770">
+    <bf:mainTitle>Revue en ligne</bf:mainTitle>
+    <schema:isPartOf rdf:resource="https://portal.issn.org/resource/ISSN-L/0000-0019"/>
+  </rdf:Description>
+  <rdf:Description rdf:about="https://portal.issn.org/resource/ISSN-L/0000-0019"/>
+</rdf:RDF>`
+
+func TestNormalizeRDFXML(t *testing.T) {
+	b, kind, err := Normalize([]byte(rdfRecord))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kind != RDFXML {
+		t.Errorf("kind: got %v, want %v", kind, RDFXML)
+	}
+	if Detect(b) != JSONLD {
+		t.Errorf("converted body is not JSON-LD: %s", b)
+	}
+	r, err := record.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ISSN != "2257-6754" || r.ISSNL != "2257-6754" || r.KeyTitle != "Revue (Print)" {
+		t.Errorf("got %s %s %q, want 2257-6754 2257-6754 %q", r.ISSN, r.ISSNL, r.KeyTitle, "Revue (Print)")
+	}
+	if r.MainTitle != "Revue imprimée" {
+		t.Errorf("main title: got %q", r.MainTitle)
+	}
+	// JSON-LD is passed through.
+	body := []byte(`{"@graph": []}`)
+	if b, kind, err := Normalize(body); err != nil || kind != JSONLD || string(b) != string(body) {
+		t.Errorf("jsonld: got %s %v %v", b, kind, err)
+	}
+	if _, err := ToJSONLD([]byte(`<?xml version="1.0"?><a/>`)); err != ErrNotRDF {
+		t.Errorf("plain xml: got %v, want %v", err, ErrNotRDF)
+	}
+}
@@ -0,0 +1,616 @@
+// Package store keeps JSON-LD bodies of ISSN records in a single
+// append-only segment file, as an alternative to one file per ISSN
+// (issnprobe) or one large uncompressed NDJSON file (issnlister -m).
+//
+// A segment file starts with a short header, followed by blocks. Each block
+// holds a directory and a compressed payload:
+//
+//	header   magic "ISSNSTO1"
+//	block    uint32 directory length, uint32 payload length, uint8 codec,
+//	         uint32 CRC-32 of directory, uint32 CRC-32 of payload,
+//	         directory, payload
+//
+// The directory lists the entries of the block: ISSN, time of fetch, hash of
+// the body and position and size of the body in the uncompressed payload.
+// Bodies are content-addressed: if a body with the same hash has been
+// stored before, the entry refers to it and no data is written again. Each
+// Put adds a version, so a record fetched at different times keeps its
+// history; Get returns the most recent version.
+//
+// Only the directories are read on Open, to build the index from ISSN to
+// versions; payloads are decompressed on access. An incomplete block at the
+// end of the file, e.g. after a crash, is truncated when opened for writing.
+// Compact rewrites a store in ISSN order, dropping old versions and bodies
+// no longer referenced.
+//
+// A store is safe for concurrent use within a process, but must only be
+// opened for writing by a single process at a time.
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/miku/issnlister/issn"
+)
+
+const (
+	magic      = "ISSNSTO1"
+	headerSize = 17 // block header: lengths, codec and checksums
+
+	// DefaultBlockSize is the amount of uncompressed data collected before
+	// a block is written.
+	DefaultBlockSize = 1 << 20
+)
+
+// Codec is the compression of a block payload.
+type Codec uint8
+
+const (
+	Zstd Codec = iota + 1
+	Gzip
+)
+
+func (c Codec) String() string {
+	switch c {
+	case Zstd:
+		return "zstd"
+	case Gzip:
+		return "gzip"
+	}
+	return fmt.Sprintf("codec(%d)", uint8(c))
+}
+
+var (
+	// ErrNotFound is returned, if there is no record for an ISSN.
+	ErrNotFound = errors.New("store: not found")
+	// ErrReadOnly is returned on writes to a store opened read-only.
+	ErrReadOnly = errors.New("store: read-only")
+	// ErrCorrupt is returned, if a block does not match its checksum.
+	ErrCorrupt = errors.New("store: corrupt block")
+	// ErrFormat is returned for files, that are not a store.
+	ErrFormat = errors.New("store: not a store file")
+)
+
+// Hash identifies a body, it is a truncated SHA-256.
+type Hash [16]byte
+
+func hashOf(body []byte) Hash {
+	var h Hash
+	sum := sha256.Sum256(body)
+	copy(h[:], sum[:])
+	return h
+}
+
+// location of a body: block offset in the file (-1 for the block not yet
+// written) and position in the uncompressed payload.
+type location struct {
+	block     int64
+	pos, size uint32
+}
+
+// Version is a record fetched at a point in time.
+type Version struct {
+	ISSN issn.ISSN
+	Time time.Time
+	Hash Hash
+}
+
+// entry is a directory entry, size 0 marks a reference to a body stored
+// before.
+type entry struct {
+	v         issn.ISSN
+	t         int64 // unix nanoseconds
+	h         Hash
+	pos, size uint32
+}
+
+// Store is an open segment file.
+type Store struct {
+	// Codec and BlockSize apply to blocks written after they are set.
+	Codec     Codec
+	BlockSize int
+
+	mu       sync.Mutex
+	f        *os.File
+	filename string
+	readOnly bool
+	size     int64                   // end of the last complete block
+	versions map[issn.ISSN][]Version // oldest first
+	bodies   map[Hash]location       // where each body is stored
+	pending  []entry                 // entries of the unwritten block
+	buf      bytes.Buffer            // payload of the unwritten block
+	cached   struct {                // last decompressed block
+		block   int64
+		payload []byte
+	}
+}
+
+// Open opens a store for reading and writing, creating it if necessary.
+func Open(filename string) (*Store, error) {
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return open(f, filename, false)
+}
+
+// OpenReadOnly opens an existing store for reading. Blocks appended by
+// another process are picked up by Refresh.
+func OpenReadOnly(filename string) (*Store, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	return open(f, filename, true)
+}
+
+func open(f *os.File, filename string, readOnly bool) (*Store, error) {
+	s := &Store{
+		Codec:     Zstd,
+		BlockSize: DefaultBlockSize,
+		f:         f,
+		filename:  filename,
+		readOnly:  readOnly,
+		versions:  make(map[issn.ISSN][]Version),
+		bodies:    make(map[Hash]location),
+	}
+	s.cached.block = -1
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.Size() == 0 && !readOnly {
+		if _, err := f.Write([]byte(magic)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	} else {
+		b := make([]byte, len(magic))
+		if _, err := io.ReadFull(f, b); err != nil || string(b) != magic {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", filename, ErrFormat)
+		}
+	}
+	s.size = int64(len(magic))
+	if err := s.scan(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// scan reads the directories of all blocks after s.size. For a writable
+// store, an incomplete trailing block is truncated.
+func (s *Store) scan() error {
+	fi, err := s.f.Stat()
+	if err != nil {
+		return err
+	}
+	var (
+		end = fi.Size()
+		br  = bufio.NewReader(io.NewSectionReader(s.f, s.size, end-s.size))
+		hdr = make([]byte, headerSize)
+		off = s.size
+	)
+	for off < end {
+		if _, err := io.ReadFull(br, hdr); err != nil {
+			break
+		}
+		var (
+			dirLen     = binary.BigEndian.Uint32(hdr[0:])
+			payloadLen = binary.BigEndian.Uint32(hdr[4:])
+			dirCRC     = binary.BigEndian.Uint32(hdr[9:])
+			blockEnd   = off + headerSize + int64(dirLen) + int64(payloadLen)
+		)
+		if blockEnd > end {
+			break
+		}
+		dir := make([]byte, dirLen)
+		if _, err := io.ReadFull(br, dir); err != nil || crc32.ChecksumIEEE(dir) != dirCRC {
+			break
+		}
+		entries, err := decodeDirectory(dir)
+		if err != nil {
+			break
+		}
+		if _, err := br.Discard(int(payloadLen)); err != nil {
+			break
+		}
+		s.index(off, entries)
+		off = blockEnd
+	}
+	if off < end {
+		if s.readOnly {
+			// Possibly a block being written right now.
+			s.size = off
+			return nil
+		}
+		if err := s.f.Truncate(off); err != nil {
+			return err
+		}
+	}
+	s.size = off
+	return nil
+}
+
+// index adds the entries of a block to the index.
+func (s *Store) index(block int64, entries []entry) {
+	for _, e := range entries {
+		if e.size > 0 {
+			s.bodies[e.h] = location{block: block, pos: e.pos, size: e.size}
+		}
+		vs := append(s.versions[e.v], Version{ISSN: e.v, Time: time.Unix(0, e.t).UTC(), Hash: e.h})
+		// Keep versions ordered by time, also if fetched out of order.
+		for i := len(vs) - 1; i > 0 && vs[i].Time.Before(vs[i-1].Time); i-- {
+			vs[i], vs[i-1] = vs[i-1], vs[i]
+		}
+		s.versions[e.v] = vs
+	}
+}
+
+// Refresh reads blocks appended since the store was opened or last
+// refreshed, by another process.
+func (s *Store) Refresh() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scan()
+}
+
+// Put adds a version of a record. A body already stored, e.g. an unchanged
+// record fetched again, is not stored twice.
+func (s *Store) Put(v issn.ISSN, t time.Time, body []byte) error {
+	if len(body) == 0 {
+		return errors.New("store: empty body")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	e := entry{v: v, t: t.UnixNano(), h: hashOf(body)}
+	if _, ok := s.bodies[e.h]; !ok {
+		e.pos, e.size = uint32(s.buf.Len()), uint32(len(body))
+		s.buf.Write(body)
+		s.bodies[e.h] = location{block: -1, pos: e.pos, size: e.size}
+	}
+	s.pending = append(s.pending, e)
+	s.index(-1, []entry{{v: e.v, t: e.t, h: e.h}})
+	if s.buf.Len() >= s.BlockSize {
+		return s.flush()
+	}
+	return nil
+}
+
+// Flush writes the pending block.
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+func (s *Store) flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	payload, err := compress(s.Codec, s.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	var (
+		dir = encodeDirectory(s.pending)
+		hdr = make([]byte, headerSize)
+	)
+	binary.BigEndian.PutUint32(hdr[0:], uint32(len(dir)))
+	binary.BigEndian.PutUint32(hdr[4:], uint32(len(payload)))
+	hdr[8] = byte(s.Codec)
+	binary.BigEndian.PutUint32(hdr[9:], crc32.ChecksumIEEE(dir))
+	binary.BigEndian.PutUint32(hdr[13:], crc32.ChecksumIEEE(payload))
+	block := s.size
+	data := append(append(hdr, dir...), payload...)
+	if _, err := s.f.WriteAt(data, block); err != nil {
+		return err
+	}
+	for _, e := range s.pending {
+		if e.size > 0 {
+			s.bodies[e.h] = location{block: block, pos: e.pos, size: e.size}
+		}
+	}
+	s.size += int64(len(data))
+	s.pending = s.pending[:0]
+	s.buf.Reset()
+	return nil
+}
+
+// Close writes the pending block and closes the file.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	if !s.readOnly {
+		err = s.flush()
+	}
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Len returns the number of ISSN in the store.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.versions)
+}
+
+// Size returns the size of the file in bytes, without the pending block.
+func (s *Store) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Bodies returns the number of distinct bodies.
+func (s *Store) Bodies() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+// Versions returns all versions of a record, oldest first.
+func (s *Store) Versions(v issn.ISSN) []Version {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Version(nil), s.versions[v]...)
+}
+
+// Get returns the most recent version of a record and its body.
+func (s *Store) Get(v issn.ISSN) (Version, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vs := s.versions[v]
+	if len(vs) == 0 {
+		return Version{}, nil, ErrNotFound
+	}
+	latest := vs[len(vs)-1]
+	body, err := s.body(latest.Hash)
+	return latest, body, err
+}
+
+// Body returns the body of a version.
+func (s *Store) Body(ver Version) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.body(ver.Hash)
+}
+
+func (s *Store) body(h Hash) ([]byte, error) {
+	loc, ok := s.bodies[h]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var payload []byte
+	switch {
+	case loc.block < 0:
+		payload = s.buf.Bytes()
+	case loc.block == s.cached.block:
+		payload = s.cached.payload
+	default:
+		var err error
+		if payload, err = s.readBlock(loc.block); err != nil {
+			return nil, err
+		}
+		s.cached.block, s.cached.payload = loc.block, payload
+	}
+	if int(loc.pos)+int(loc.size) > len(payload) {
+		return nil, fmt.Errorf("%s: block at %d: %w", s.filename, loc.block, ErrCorrupt)
+	}
+	return append([]byte(nil), payload[loc.pos:loc.pos+loc.size]...), nil
+}
+
+// readBlock returns the uncompressed payload of the block at an offset.
+func (s *Store) readBlock(block int64) ([]byte, error) {
+	hdr := make([]byte, headerSize)
+	if _, err := s.f.ReadAt(hdr, block); err != nil {
+		return nil, err
+	}
+	var (
+		dirLen     = binary.BigEndian.Uint32(hdr[0:])
+		payloadLen = binary.BigEndian.Uint32(hdr[4:])
+		codec      = Codec(hdr[8])
+		payloadCRC = binary.BigEndian.Uint32(hdr[13:])
+		payload    = make([]byte, payloadLen)
+	)
+	if _, err := s.f.ReadAt(payload, block+headerSize+int64(dirLen)); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != payloadCRC {
+		return nil, fmt.Errorf("%s: block at %d: %w", s.filename, block, ErrCorrupt)
+	}
+	return decompress(codec, payload)
+}
+
+// Keys returns all ISSN in the store, in order.
+func (s *Store) Keys() []issn.ISSN {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]issn.ISSN, 0, len(s.versions))
+	for v := range s.versions {
+		keys = append(keys, v)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// Iter calls f with the most recent version of every record, in ISSN
+// order. Iteration is fastest on a compacted store, where records are
+// stored in that order.
+func (s *Store) Iter(f func(ver Version, body []byte) error) error {
+	for _, v := range s.Keys() {
+		ver, body, err := s.Get(v)
+		if err != nil {
+			return err
+		}
+		if err := f(ver, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compact writes the records of a store in ISSN order to a new store,
+// keeping at most keep versions per ISSN (0 keeps all) and only the bodies
+// still referenced, then replaces the store file. The store is closed
+// afterwards.
+func (s *Store) Compact(keep int) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	tmp := s.filename + ".compact"
+	os.Remove(tmp)
+	dst, err := Open(tmp)
+	if err != nil {
+		return err
+	}
+	dst.Codec, dst.BlockSize = s.Codec, s.BlockSize
+	for _, v := range s.Keys() {
+		vs := s.Versions(v)
+		if keep > 0 && len(vs) > keep {
+			vs = vs[len(vs)-keep:]
+		}
+		for _, ver := range vs {
+			body, err := s.Body(ver)
+			if err == nil {
+				err = dst.Put(v, ver.Time, body)
+			}
+			if err != nil {
+				dst.Close()
+				os.Remove(tmp)
+				return err
+			}
+		}
+	}
+	err = dst.Flush()
+	if err == nil {
+		err = dst.f.Sync()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := s.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.filename)
+}
+
+// encodeDirectory serializes block entries.
+func encodeDirectory(entries []entry) []byte {
+	b := binary.AppendUvarint(nil, uint64(len(entries)))
+	for _, e := range entries {
+		b = binary.BigEndian.AppendUint32(b, uint32(e.v))
+		b = binary.AppendVarint(b, e.t)
+		b = append(b, e.h[:]...)
+		b = binary.AppendUvarint(b, uint64(e.pos))
+		b = binary.AppendUvarint(b, uint64(e.size))
+	}
+	return b
+}
+
+func decodeDirectory(b []byte) ([]entry, error) {
+	r := bytes.NewReader(b)
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > uint64(len(b)) {
+		return nil, ErrCorrupt
+	}
+	entries := make([]entry, n)
+	for i := range entries {
+		e := &entries[i]
+		var v uint32
+		if err := binary.Read(r, binary.BigEndian, &v); err != nil || v > issn.MaxPrefix {
+			return nil, ErrCorrupt
+		}
+		e.v = issn.ISSN(v)
+		if e.t, err = binary.ReadVarint(r); err != nil {
+			return nil, ErrCorrupt
+		}
+		if _, err := io.ReadFull(r, e.h[:]); err != nil {
+			return nil, ErrCorrupt
+		}
+		pos, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, ErrCorrupt
+		}
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, ErrCorrupt
+		}
+		e.pos, e.size = uint32(pos), uint32(size)
+	}
+	return entries, nil
+}
+
+var (
+	zstdOnce sync.Once
+	zstdEnc  *zstd.Encoder
+	zstdDec  *zstd.Decoder
+)
+
+func initZstd() {
+	zstdEnc, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	zstdDec, _ = zstd.NewReader(nil)
+}
+
+func compress(c Codec, data []byte) ([]byte, error) {
+	switch c {
+	case Zstd:
+		zstdOnce.Do(initZstd)
+		return zstdEnc.EncodeAll(data, nil), nil
+	case Gzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("store: unknown codec: %v", c)
+}
+
+func decompress(c Codec, data []byte) ([]byte, error) {
+	switch c {
+	case Zstd:
+		zstdOnce.Do(initZstd)
+		return zstdDec.DecodeAll(data, nil)
+	case Gzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	}
+	return nil, fmt.Errorf("store: unknown codec: %v", c)
+}
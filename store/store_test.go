@@ -0,0 +1,116 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miku/issnlister/issn"
+)
+
+func TestStore(t *testing.T) {
+	var (
+		filename = filepath.Join(t.TempDir(), "data.store")
+		a        = issn.MustParse("0378-5955")
+		b        = issn.MustParse("2434-561X")
+		t0       = time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+		t1       = t0.Add(24 * time.Hour)
+	)
+	s, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Out of order and an unchanged body, which is only stored once.
+	for _, p := range []struct {
+		v    issn.ISSN
+		t    time.Time
+		body string
+	}{
+		{a, t1, "a1"},
+		{a, t0, "a0"},
+		{b, t0, "b0"},
+		{b, t1, "b0"},
+	} {
+		if err := s.Put(p.v, p.t, []byte(p.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	complete := s.Size()
+	// A block cut off by a crash.
+	c := issn.MustParse("0000-0019")
+	if err := s.Put(c, t1, []byte("c1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(filename, fi.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(s *Store) {
+		t.Helper()
+		if n := s.Len(); n != 2 {
+			t.Errorf("got %d records, want 2", n)
+		}
+		if n := s.Bodies(); n != 3 {
+			t.Errorf("got %d bodies, want 3", n)
+		}
+		ver, body, err := s.Get(a)
+		if err != nil || string(body) != "a1" || !ver.Time.Equal(t1) {
+			t.Errorf("get %s: got %v %q %v", a, ver.Time, body, err)
+		}
+		vs := s.Versions(a)
+		if len(vs) != 2 || !vs[0].Time.Equal(t0) {
+			t.Fatalf("versions: got %v", vs)
+		}
+		if body, err := s.Body(vs[0]); err != nil || string(body) != "a0" {
+			t.Errorf("oldest version: got %q %v", body, err)
+		}
+		if _, _, err := s.Get(c); !errors.Is(err, ErrNotFound) {
+			t.Errorf("record of truncated block: got %v", err)
+		}
+	}
+
+	if s, err = OpenReadOnly(filename); err != nil {
+		t.Fatal(err)
+	}
+	check(s)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = Open(filename); err != nil {
+		t.Fatal(err)
+	}
+	check(s)
+	if fi, err := os.Stat(filename); err != nil || fi.Size() != complete {
+		t.Errorf("incomplete block not truncated: %v %v, want size %d", fi.Size(), err, complete)
+	}
+	if err := s.Compact(1); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = Open(filename); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if n := len(s.Versions(a)); n != 1 {
+		t.Errorf("got %d versions after compact, want 1", n)
+	}
+	if n := s.Bodies(); n != 2 {
+		t.Errorf("got %d bodies after compact, want 2", n)
+	}
+	for v, want := range map[issn.ISSN]string{a: "a1", b: "b0"} {
+		if _, body, err := s.Get(v); err != nil || string(body) != want {
+			t.Errorf("get %s after compact: got %q %v, want %q", v, body, err, want)
+		}
+	}
+}
@@ -8,9 +8,13 @@ import (
 )
 
 // SliceReader turns a slice of strings into a reader simulating a newline
-// delimited file.
+// delimited file. Each line is terminated, as readers may drop an
+// unterminated last line.
 func SliceReader(s []string) io.Reader {
-	return strings.NewReader(strings.Join(s, "\n"))
+	if len(s) == 0 {
+		return strings.NewReader("")
+	}
+	return strings.NewReader(strings.Join(s, "\n") + "\n")
 }
 
 // StringSet is map disguised as set.